@@ -4,14 +4,17 @@ import (
 	"math"
 
 	"github.com/Zac-Garby/pieces-of-seven/geom"
-	"github.com/Zac-Garby/pieces-of-seven/loader"
 	"github.com/Zac-Garby/pieces-of-seven/world"
-	"github.com/veandco/go-sdl2/sdl"
 )
 
-// ShipSpeed is the speed of the ship's apparent movement,
-// in pixels per second.
-const ShipSpeed = 20
+// ShipSpeed is the speed of a ship, in tiles per second.
+// It's the same on the client and the server, so they
+// both agree on where a ship is.
+const ShipSpeed = 3.0
+
+// SyncTolerance is how far apart, in tiles, two views of
+// the same ship can be before they're out of sync.
+const SyncTolerance = 1.5
 
 // A Ship (an Entity,) is a player's ship.
 type Ship struct {
@@ -37,7 +40,8 @@ func NewShip(x, y uint) *Ship {
 }
 
 // Move sets the ship's current movement path
-// to one going to (x, y).
+// to one going to (x, y). If (x, y) can't be reached,
+// the ship goes as near to it as it can instead.
 func (s *Ship) Move(to geom.Coord, in *world.World) {
 	start := s.Pos
 
@@ -45,7 +49,7 @@ func (s *Ship) Move(to geom.Coord, in *world.World) {
 		start = s.Path[0]
 	}
 
-	coords, found := in.Graph.FindPath(start, to)
+	coords, found := in.FindNearestPath(start, to)
 	if !found {
 		return
 	}
@@ -58,22 +62,26 @@ func (s *Ship) Move(to geom.Coord, in *world.World) {
 	s.Path = path
 }
 
-// Render renders the ship on the given renderer.
-func (s *Ship) Render(viewOffset *geom.Vector, ld *loader.Loader, rend *sdl.Renderer) {
-	rend.SetDrawColor(255, 0, 0, 255)
-
-	tex := ld.Textures["ship"]
+// Follow sets the ship's current movement path to one
+// following a flow field to its target. It's cheaper
+// than Move when lots of ships head to the same place.
+// It returns false, leaving the path alone, if the
+// target can't be reached.
+func (s *Ship) Follow(field *world.FlowField) bool {
+	start := s.Pos
 
-	src := s.getSheetRect()
+	if len(s.Path) > 0 {
+		start = s.Path[0]
+	}
 
-	dst := &sdl.Rect{
-		X: int32(s.ApparentPos.X*world.TileSize) - int32(viewOffset.X),
-		Y: int32(s.ApparentPos.Y*world.TileSize) - int32(viewOffset.Y),
-		W: int32(world.TileSize),
-		H: int32(world.TileSize),
+	coords, found := field.Path(start)
+	if !found {
+		return false
 	}
 
-	rend.Copy(tex, src, dst)
+	s.Path = Path(coords)
+
+	return true
 }
 
 // Step is called every tick
@@ -81,80 +89,100 @@ func (s *Ship) Step() {
 
 }
 
-// Update moves the ship's apparent position towards its destination.
+// Update moves the ship's apparent position towards its
+// destination, at ShipSpeed tiles per second.
 func (s *Ship) Update(dt float64) {
-	if len(s.Path) == 0 {
-		return
-	}
+	step := ShipSpeed * dt
 
-	NeXTStep := s.Path[0]
+	for len(s.Path) > 0 && step > 0 {
+		next := s.Path[0]
 
-	diff := geom.Vector{
-		X: float64(NeXTStep.X) - float64(s.ApparentPos.X),
-		Y: float64(NeXTStep.Y) - float64(s.ApparentPos.Y),
-	}
+		diff := geom.Vector{
+			X: float64(next.X) - s.ApparentPos.X,
+			Y: float64(next.Y) - s.ApparentPos.Y,
+		}
 
-	dist := math.Sqrt(diff.X*diff.X + diff.Y*diff.Y)
+		dist := math.Sqrt(diff.X*diff.X + diff.Y*diff.Y)
 
-	if dist < 0.1 {
-		s.Path = s.Path[1:]
+		if dist <= step {
+			// Arrived at the next coordinate, so any
+			// leftover movement carries on along the path.
+			s.Path = s.Path[1:]
+			s.Pos = next
 
-		s.Pos = NeXTStep
+			s.ApparentPos.X = float64(next.X)
+			s.ApparentPos.Y = float64(next.Y)
 
-		s.ApparentPos.X = float64(s.Pos.X)
-		s.ApparentPos.Y = float64(s.Pos.Y)
-	} else {
-		movement := geom.Vector{
-			X: diff.X / dist / ShipSpeed,
-			Y: diff.Y / dist / ShipSpeed,
+			step -= dist
+		} else {
+			s.ApparentPos.X += diff.X / dist * step
+			s.ApparentPos.Y += diff.Y / dist * step
+
+			step = 0
 		}
 
-		s.ApparentPos.X += movement.X
-		s.ApparentPos.Y += movement.Y
+		s.direction = s.diffToDirection(diff)
 	}
+}
 
-	d := geom.Vector{
-		X: float64(s.ApparentPos.X) - float64(s.Pos.X),
-		Y: float64(s.ApparentPos.Y) - float64(s.Pos.Y),
+// SetApparentPos moves the ship's apparent position
+// directly, turning the ship to face the way it moved.
+// It's used for ships which aren't simulated locally.
+func (s *Ship) SetApparentPos(pos geom.Vector) {
+	diff := geom.Vector{
+		X: pos.X - s.ApparentPos.X,
+		Y: pos.Y - s.ApparentPos.Y,
 	}
 
-	s.direction = s.diffToDirection(d)
+	s.ApparentPos = pos
+	s.direction = s.diffToDirection(diff)
 }
 
-func (s *Ship) getSheetRect() *sdl.Rect {
-	var (
-		x = 0
-		y = 0
-	)
-
-	switch s.direction {
-	case 0:
-		x = 3
-		y = 1
-	case 1:
-		break
-	case 2:
-		y = 1
-	case 3:
-		x = 1
-	case 4:
-		x = 1
-		y = 1
-	case 5:
-		x = 2
-	case 6:
-		x = 2
-		y = 1
-	case 7:
-		x = 3
+// NextStep returns the next tile the ship is sailing
+// to, or false if it isn't moving.
+func (s *Ship) NextStep() (geom.Coord, bool) {
+	for _, coord := range s.Path {
+		if coord != s.Pos {
+			return coord, true
+		}
 	}
 
-	return &sdl.Rect{
-		X: int32(x * 15),
-		Y: int32(y * 15),
-		W: 15,
-		H: 15,
+	return geom.Coord{}, false
+}
+
+// Moving checks whether the ship is part of the way
+// between two tiles.
+func (s *Ship) Moving() bool {
+	return s.ApparentPos.X != float64(s.Pos.X) || s.ApparentPos.Y != float64(s.Pos.Y)
+}
+
+// Destination returns the coordinate the ship is sailing
+// to, which is its position if it's not moving.
+func (s *Ship) Destination() geom.Coord {
+	if len(s.Path) == 0 {
+		return s.Pos
 	}
+
+	return s.Path[len(s.Path)-1]
+}
+
+// Distance returns how far the ship's apparent position
+// is from the given point, in tiles.
+func (s *Ship) Distance(to geom.Vector) float64 {
+	dx := s.ApparentPos.X - to.X
+	dy := s.ApparentPos.Y - to.Y
+
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// Direction returns the way the ship is facing, from 0
+// to 7:
+//
+// 0 1 2
+// 7 x 3
+// 6 5 4
+func (s *Ship) Direction() uint8 {
+	return s.direction
 }
 
 func (s *Ship) diffToDirection(diff geom.Vector) uint8 {
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"time"
 
 	"github.com/Zac-Garby/pieces-of-seven/scene/mainmenu"
@@ -19,9 +21,17 @@ const (
 var (
 	scn scene.Scene
 	ld  loader.Loader
+
+	frameTime = flag.Bool("frametime", false, "print how long frames take to update and render, every few seconds")
 )
 
+// FrameTimeInterval is how often frame times are
+// printed, with the -frametime flag.
+const FrameTimeInterval = 5 * time.Second
+
 func main() {
+	flag.Parse()
+
 	sdl.Init(sdl.INIT_EVERYTHING)
 	defer sdl.Quit()
 
@@ -41,6 +51,15 @@ func main() {
 		"water": {Path: "assets/tiles/water.png", Type: loader.Texture, Data: make(map[string]int)},
 		"sand":  {Path: "assets/tiles/sand.png", Type: loader.Texture, Data: make(map[string]int)},
 
+		"deep-water":    {Path: "assets/tiles/deep-water.png", Type: loader.Texture, Data: make(map[string]int)},
+		"shallow-water": {Path: "assets/tiles/shallow-water.png", Type: loader.Texture, Data: make(map[string]int)},
+		"reef":          {Path: "assets/tiles/reef.png", Type: loader.Texture, Data: make(map[string]int)},
+		"beach":         {Path: "assets/tiles/beach.png", Type: loader.Texture, Data: make(map[string]int)},
+		"beach-surf":    {Path: "assets/tiles/beach-surf.png", Type: loader.Texture, Data: make(map[string]int)},
+		"grass":         {Path: "assets/tiles/grass.png", Type: loader.Texture, Data: make(map[string]int)},
+		"forest":        {Path: "assets/tiles/forest.png", Type: loader.Texture, Data: make(map[string]int)},
+		"rock":          {Path: "assets/tiles/rock.png", Type: loader.Texture, Data: make(map[string]int)},
+
 		// Fonts
 		"body":    {Path: "assets/fonts/Montserrat-Regular.ttf", Type: loader.Font, Data: map[string]int{"size": 25}},
 		"body-sm": {Path: "assets/fonts/Montserrat-Regular.ttf", Type: loader.Font, Data: map[string]int{"size": 20}},
@@ -68,6 +87,13 @@ func main() {
 
 	last := time.Now()
 
+	var (
+		frames     int
+		frameTotal time.Duration
+		frameWorst time.Duration
+		lastReport = time.Now()
+	)
+
 	running := true
 main:
 	for running {
@@ -105,6 +131,26 @@ main:
 
 		scn.Render(renderer, width, height)
 
+		// The time is measured before presenting, which
+		// waits for VSync.
+		if *frameTime {
+			took := time.Since(last)
+
+			frames++
+			frameTotal += took
+
+			if took > frameWorst {
+				frameWorst = took
+			}
+
+			if time.Since(lastReport) >= FrameTimeInterval {
+				fmt.Printf("frame time: %v average, %v worst, over %d frames\n", frameTotal/time.Duration(frames), frameWorst, frames)
+
+				frames, frameTotal, frameWorst = 0, 0, 0
+				lastReport = time.Now()
+			}
+		}
+
 		renderer.Present()
 	}
 }
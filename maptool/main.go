@@ -0,0 +1,150 @@
+// Command maptool converts between worlds and PNG map
+// images, so maps can be painted in an image editor. It
+// can also import maps made in Tiled.
+//
+//	maptool import [-palette file] map.png|map.tmx|map.json out.world
+//	maptool export [-palette file] [-scale n] in.world out.png
+//	maptool export [-seed n] [-generator name] out.png
+//
+// Exporting without a world file generates one, the same
+// way the server would. As with the server, a seed of 0
+// picks a random one, which is printed so the world can
+// be generated again.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Zac-Garby/pieces-of-seven/world"
+)
+
+// MaxScale is the largest -scale an image can be exported
+// with. Each tile takes 4 bytes for each of its scale by
+// scale pixels, so big scales quickly run out of memory.
+const MaxScale = 16
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "import":
+		err = importMap(os.Args[2:])
+
+	case "export":
+		err = exportMap(os.Args[2:])
+
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: maptool import [-palette file] map.png|map.tmx|map.json out.world")
+	fmt.Fprintln(os.Stderr, "       maptool export [-palette file] [-scale n] [-seed n] [-generator name] [in.world] out.png")
+	fmt.Fprintln(os.Stderr, "without in.world, a world is generated from -seed, or a random seed if it's 0")
+	os.Exit(2)
+}
+
+// palette loads the palette at path, or returns the
+// default one if there's no path.
+func palette(path string) (world.Palette, error) {
+	if path == "" {
+		return world.DefaultPalette(), nil
+	}
+
+	return world.LoadPalette(path)
+}
+
+func importMap(args []string) error {
+	var (
+		flags       = flag.NewFlagSet("import", flag.ExitOnError)
+		palettePath = flags.String("palette", "", "a JSON file mapping tile names to colours")
+	)
+
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		usage()
+	}
+
+	p, err := palette(*palettePath)
+	if err != nil {
+		return err
+	}
+
+	w, err := world.ImportFile(flags.Arg(0), p)
+	if err != nil {
+		return err
+	}
+
+	return w.SaveFile(flags.Arg(1))
+}
+
+func exportMap(args []string) error {
+	var (
+		flags       = flag.NewFlagSet("export", flag.ExitOnError)
+		palettePath = flags.String("palette", "", "a JSON file mapping tile names to colours")
+		scale       = flags.Int("scale", 1, fmt.Sprintf("the width and height, in pixels, of each tile, from 1 to %d", MaxScale))
+		seed        = flags.Int64("seed", 0, "the seed to generate a world from, if there's no world file, or 0 for a random one")
+		algorithm   = flags.String("generator", string(world.DefaultGeneratorConfig.Algorithm), "the algorithm to generate a world with: cellular or noise")
+	)
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 && flags.NArg() != 2 {
+		usage()
+	}
+
+	if *scale < 1 || *scale > MaxScale {
+		return fmt.Errorf("invalid scale %d: must be between 1 and %d", *scale, MaxScale)
+	}
+
+	p, err := palette(*palettePath)
+	if err != nil {
+		return err
+	}
+
+	var w *world.World
+
+	if flags.NArg() == 2 {
+		w, err = world.LoadFile(flags.Arg(0))
+	} else {
+		cfg := world.DefaultGeneratorConfig
+		cfg.Algorithm = world.Algorithm(*algorithm)
+
+		if *seed == 0 {
+			*seed = time.Now().UnixNano()
+			fmt.Println("world seed:", *seed)
+		}
+
+		w, err = world.Generate(*seed, cfg)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(flags.Arg(flags.NArg() - 1))
+	if err != nil {
+		return err
+	}
+
+	if err := w.ExportImage(out, p, *scale); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
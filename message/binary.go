@@ -0,0 +1,500 @@
+package message
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+)
+
+// The binary codec walks a message's fields with
+// reflection, writing each value as compactly as it
+// can:
+//
+//  - unsigned integers are written as uvarints, and
+//    signed ones as zig-zag varints
+//  - floats are written as their IEEE 754 bits
+//  - bools are a single byte
+//  - strings and slices are prefixed by their length,
+//    and empty slices are decoded as nil
+//  - arrays are just their elements, one after another
+//  - maps are prefixed by their length, followed by
+//    each key and value
+//  - structs are their exported fields, in order
+//  - pointers are a byte saying whether they're nil,
+//    followed by the value they point to
+//  - anything implementing encoding.BinaryMarshaler,
+//    such as time.Time, is written as a byte slice
+//
+// Both sides need to agree on the exact types of each
+// message, which the handshake ensures by checking
+// the protocol version.
+
+// ErrShortBuffer is returned when the binary codec
+// runs out of data part of the way through a value.
+var ErrShortBuffer = errors.New("binary: unexpected end of data")
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+type binaryCodec struct{}
+
+func (binaryCodec) Name() string {
+	return "binary"
+}
+
+func (binaryCodec) Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+
+	// Messages are usually given as pointers, but they're
+	// always unmarshalled into one, so the pointer itself
+	// isn't part of the encoding.
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if !rv.IsValid() || rv.Kind() == reflect.Ptr {
+		return nil, fmt.Errorf("binary: can't marshal %T", v)
+	}
+
+	e := &binaryEncoder{}
+
+	if err := e.encode(rv); err != nil {
+		return nil, err
+	}
+
+	return e.buf, nil
+}
+
+func (binaryCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("binary: can't unmarshal into %T", v)
+	}
+
+	d := &binaryDecoder{data: data}
+
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+
+	if len(d.data) > 0 {
+		return fmt.Errorf("binary: %d bytes left over", len(d.data))
+	}
+
+	return nil
+}
+
+type binaryEncoder struct {
+	buf     []byte
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (e *binaryEncoder) uvarint(x uint64) {
+	n := binary.PutUvarint(e.scratch[:], x)
+	e.buf = append(e.buf, e.scratch[:n]...)
+}
+
+func (e *binaryEncoder) varint(x int64) {
+	n := binary.PutVarint(e.scratch[:], x)
+	e.buf = append(e.buf, e.scratch[:n]...)
+}
+
+func (e *binaryEncoder) encode(v reflect.Value) error {
+	t := v.Type()
+
+	if t.Implements(binaryMarshalerType) && t.Kind() != reflect.Ptr {
+		b, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		e.uvarint(uint64(len(b)))
+		e.buf = append(e.buf, b...)
+
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 1)
+		} else {
+			e.buf = append(e.buf, 0)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.varint(v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		e.uvarint(v.Uint())
+
+	case reflect.Float32:
+		e.buf = append(e.buf, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(e.buf[len(e.buf)-4:], math.Float32bits(float32(v.Float())))
+
+	case reflect.Float64:
+		e.buf = append(e.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint64(e.buf[len(e.buf)-8:], math.Float64bits(v.Float()))
+
+	case reflect.String:
+		e.uvarint(uint64(v.Len()))
+		e.buf = append(e.buf, v.String()...)
+
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			for i := 0; i < v.Len(); i++ {
+				e.buf = append(e.buf, byte(v.Index(i).Uint()))
+			}
+
+			return nil
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		e.uvarint(uint64(v.Len()))
+
+		if t.Elem().Kind() == reflect.Uint8 {
+			e.buf = append(e.buf, v.Bytes()...)
+			return nil
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		e.uvarint(uint64(v.Len()))
+
+		for _, key := range v.MapKeys() {
+			if err := e.encode(key); err != nil {
+				return err
+			}
+
+			if err := e.encode(v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+
+			if err := e.encode(v.Field(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0)
+			return nil
+		}
+
+		e.buf = append(e.buf, 1)
+
+		return e.encode(v.Elem())
+
+	default:
+		return fmt.Errorf("binary: can't marshal %s", t)
+	}
+
+	return nil
+}
+
+type binaryDecoder struct {
+	data []byte
+}
+
+func (d *binaryDecoder) uvarint() (uint64, error) {
+	x, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, ErrShortBuffer
+	}
+
+	d.data = d.data[n:]
+
+	return x, nil
+}
+
+func (d *binaryDecoder) varint() (int64, error) {
+	x, n := binary.Varint(d.data)
+	if n <= 0 {
+		return 0, ErrShortBuffer
+	}
+
+	d.data = d.data[n:]
+
+	return x, nil
+}
+
+func (d *binaryDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)) {
+		return nil, ErrShortBuffer
+	}
+
+	b := d.data[:n]
+	d.data = d.data[n:]
+
+	return b, nil
+}
+
+// length reads a length prefix, for elements which each
+// take at least size bytes. A length whose elements
+// couldn't fit in the remaining data must be corrupt, and
+// is rejected before anything is allocated, so a small
+// message can never make the decoder allocate much more
+// memory than it takes up.
+func (d *binaryDecoder) length(size int) (int, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return 0, err
+	}
+
+	if size < 1 {
+		size = 1
+	}
+
+	if n > uint64(len(d.data)/size) {
+		return 0, ErrShortBuffer
+	}
+
+	return int(n), nil
+}
+
+// minSizes caches the result of minSize for each type.
+var minSizes sync.Map
+
+// minSize returns the fewest bytes which a value of type
+// t can be encoded in.
+func minSize(t reflect.Type) int {
+	if size, ok := minSizes.Load(t); ok {
+		return size.(int)
+	}
+
+	size := 1
+
+	switch {
+	case reflect.PtrTo(t).Implements(binaryUnmarshalerType) && t.Kind() != reflect.Ptr:
+		// Just the length prefix.
+
+	case t.Kind() == reflect.Float32:
+		size = 4
+
+	case t.Kind() == reflect.Float64:
+		size = 8
+
+	case t.Kind() == reflect.Array:
+		size = t.Len() * minSize(t.Elem())
+
+	case t.Kind() == reflect.Struct:
+		size = 0
+
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" {
+				size += minSize(t.Field(i).Type)
+			}
+		}
+	}
+
+	minSizes.Store(t, size)
+
+	return size
+}
+
+func (d *binaryDecoder) decode(v reflect.Value) error {
+	t := v.Type()
+
+	if reflect.PtrTo(t).Implements(binaryUnmarshalerType) && t.Kind() != reflect.Ptr {
+		n, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+
+		b, err := d.take(n)
+		if err != nil {
+			return err
+		}
+
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := d.take(1)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b[0] != 0)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := d.varint()
+		if err != nil {
+			return err
+		}
+
+		if v.OverflowInt(x) {
+			return fmt.Errorf("binary: %d overflows %s", x, t)
+		}
+
+		v.SetInt(x)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+
+		if v.OverflowUint(x) {
+			return fmt.Errorf("binary: %d overflows %s", x, t)
+		}
+
+		v.SetUint(x)
+
+	case reflect.Float32:
+		b, err := d.take(4)
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+
+	case reflect.Float64:
+		b, err := d.take(8)
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+
+	case reflect.String:
+		n, err := d.length(1)
+		if err != nil {
+			return err
+		}
+
+		b, _ := d.take(uint64(n))
+		v.SetString(string(b))
+
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			b, err := d.take(uint64(v.Len()))
+			if err != nil {
+				return err
+			}
+
+			reflect.Copy(v, reflect.ValueOf(b))
+
+			return nil
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if err := d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		n, err := d.length(minSize(t.Elem()))
+		if err != nil {
+			return err
+		}
+
+		// Empty and nil slices are encoded in the same
+		// way, and are always decoded as nil.
+		if n == 0 {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+
+		if t.Elem().Kind() == reflect.Uint8 {
+			b, _ := d.take(uint64(n))
+			v.SetBytes(append([]byte{}, b...))
+
+			return nil
+		}
+
+		s := reflect.MakeSlice(t, n, n)
+
+		for i := 0; i < n; i++ {
+			if err := d.decode(s.Index(i)); err != nil {
+				return err
+			}
+		}
+
+		v.Set(s)
+
+	case reflect.Map:
+		n, err := d.length(minSize(t.Key()) + minSize(t.Elem()))
+		if err != nil {
+			return err
+		}
+
+		m := reflect.MakeMapWithSize(t, n)
+
+		for i := 0; i < n; i++ {
+			key := reflect.New(t.Key()).Elem()
+			if err := d.decode(key); err != nil {
+				return err
+			}
+
+			val := reflect.New(t.Elem()).Elem()
+			if err := d.decode(val); err != nil {
+				return err
+			}
+
+			m.SetMapIndex(key, val)
+		}
+
+		v.Set(m)
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+
+			if err := d.decode(v.Field(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Ptr:
+		b, err := d.take(1)
+		if err != nil {
+			return err
+		}
+
+		if b[0] == 0 {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+
+		p := reflect.New(t.Elem())
+		if err := d.decode(p.Elem()); err != nil {
+			return err
+		}
+
+		v.Set(p)
+
+	default:
+		return fmt.Errorf("binary: can't unmarshal %s", t)
+	}
+
+	return nil
+}
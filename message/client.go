@@ -9,10 +9,27 @@ import (
 // This file contains messages sent from
 // the client to the server.
 
+func init() {
+	Register(10, ClientToServer, Disconnect{})
+	Register(11, ClientToServer, Moved{})
+	Register(12, ClientToServer, StateUpdate{})
+
+	// Chat messages are sent to the server, which
+	// then broadcasts them to every client.
+	Register(13, Both, ChatMessage{})
+}
+
 // ClientInfo tells the server information
-// about the client.
+// about the client. It's always the first
+// message a client sends, and begins the
+// handshake. Codecs are the names of the
+// codecs the client supports, in order of
+// preference.
 type ClientInfo struct {
-	Name string
+	Name         string
+	Version      int
+	Capabilities []string
+	Codecs       []string
 }
 
 // A Disconnect message tells the server
@@ -20,9 +37,12 @@ type ClientInfo struct {
 type Disconnect struct{}
 
 // Moved tells the server that the client
-// has moved, and where he moved to.
+// has moved, and where he moved to. Seq
+// increases with every move, so the server
+// can acknowledge which moves it's seen.
 type Moved struct {
 	Position geom.Coord
+	Seq      uint32
 }
 
 // A StateUpdate is sent peridocally from
@@ -33,11 +53,25 @@ type StateUpdate struct {
 	Position geom.Coord
 }
 
+// A ChatType says what kind of chat message a
+// message is. Each type is a separate bit, so they
+// can be combined into a mask.
+type ChatType int
+
+// These are the types of chat message.
+const (
+	NoneChat   ChatType = 0
+	GlobalChat ChatType = 1 << (iota - 1)
+	PrivateChat
+	ServerChat
+	DebugChat
+)
+
 // A ChatMessage tells the server that the
 // client has sent a message.
 type ChatMessage struct {
 	Sender  string
 	Content string
 	Time    time.Time
-	Type    int
+	Type    ChatType
 }
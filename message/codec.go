@@ -0,0 +1,78 @@
+package message
+
+import "encoding/json"
+
+// A Codec turns the body of a message into bytes, and
+// back again. The prefix which identifies the type of
+// a message isn't part of the body, so it's the same
+// no matter which codec is used.
+type Codec interface {
+	// Name is the name used to choose the codec
+	// during the handshake.
+	Name() string
+
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSON encodes messages as JSON. It's used for the
+	// handshake, before a codec has been chosen.
+	JSON Codec = jsonCodec{}
+
+	// Binary encodes messages in a compact binary format.
+	Binary Codec = binaryCodec{}
+)
+
+// Codecs contains every supported codec, in order of
+// preference.
+var Codecs = []Codec{Binary, JSON}
+
+// CodecNames returns the names of the codecs in Codecs.
+func CodecNames() []string {
+	names := make([]string, len(Codecs))
+
+	for i, c := range Codecs {
+		names[i] = c.Name()
+	}
+
+	return names
+}
+
+// GetCodec returns the codec with the given name, and
+// false if there isn't one.
+func GetCodec(name string) (Codec, bool) {
+	for _, c := range Codecs {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+// ChooseCodec returns the first of the named codecs
+// which is supported, and false if none of them are.
+func ChooseCodec(names []string) (Codec, bool) {
+	for _, name := range names {
+		if c, ok := GetCodec(name); ok {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
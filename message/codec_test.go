@@ -0,0 +1,257 @@
+package message
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+// registered returns an example of every registered type
+// of message, in order of their IDs, with every field
+// filled in.
+func registered() []interface{} {
+	var ids []int
+
+	for id := range byID {
+		ids = append(ids, int(id))
+	}
+
+	sort.Ints(ids)
+
+	msgs := make([]interface{}, len(ids))
+
+	for i, id := range ids {
+		v := reflect.New(byID[ID(id)].typ)
+		fill(v.Elem(), id)
+
+		msgs[i] = v.Interface()
+	}
+
+	return msgs
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fill sets every exported field of v to something which
+// isn't its zero value, depending on n.
+func fill(v reflect.Value, n int) {
+	if v.Type() == timeType {
+		v.Set(reflect.ValueOf(time.Unix(int64(n)*1000, int64(n)).UTC()))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(-n - 1))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(n + 1))
+
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(n) + 0.5)
+
+	case reflect.String:
+		v.SetString("héllo")
+
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), n+i)
+		}
+
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), 2, 2)
+
+		for i := 0; i < s.Len(); i++ {
+			fill(s.Index(i), n+i)
+		}
+
+		v.Set(s)
+
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+
+		for i := 0; i < 2; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			fill(key, n+i)
+
+			val := reflect.New(v.Type().Elem()).Elem()
+			fill(val, n+i)
+
+			m.SetMapIndex(key, val)
+		}
+
+		v.Set(m)
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				fill(v.Field(i), n+i)
+			}
+		}
+
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		fill(p.Elem(), n)
+
+		v.Set(p)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	msgs := registered()
+
+	for _, codec := range Codecs {
+		for _, msg := range msgs {
+			b, err := SerializeWith(codec, msg)
+			if err != nil {
+				t.Errorf("%s: serializing %T: %s", codec.Name(), msg, err)
+				continue
+			}
+
+			out, err := DeserializeWith(codec, b)
+			if err != nil {
+				t.Errorf("%s: deserializing %T: %s", codec.Name(), msg, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(out, msg) {
+				t.Errorf("%s: %T came back as %+v, want %+v", codec.Name(), msg, out, msg)
+			}
+		}
+	}
+}
+
+func TestRoundTripEmpty(t *testing.T) {
+	for _, codec := range Codecs {
+		for id, reg := range byID {
+			msg := reflect.New(reg.typ).Interface()
+
+			b, err := SerializeWith(codec, msg)
+			if err != nil {
+				t.Errorf("%s: serializing %T: %s", codec.Name(), msg, err)
+				continue
+			}
+
+			out, err := DeserializeWith(codec, b)
+			if err != nil {
+				t.Errorf("%s: deserializing %T: %s", codec.Name(), msg, err)
+				continue
+			}
+
+			if oid, _ := IDOf(out); oid != id {
+				t.Errorf("%s: %T came back as %T", codec.Name(), msg, out)
+			}
+		}
+	}
+}
+
+func TestBinaryTruncated(t *testing.T) {
+	for _, msg := range registered() {
+		b, err := SerializeWith(Binary, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Every prefix of a message is missing something,
+		// so none of them should decode.
+		for i := 0; i < len(b); i++ {
+			if _, err := DeserializeWith(Binary, b[:i]); err == nil {
+				t.Errorf("%T: the first %d of %d bytes decoded", msg, i, len(b))
+			}
+		}
+	}
+}
+
+// big is a type which takes up much more memory than its
+// smallest encoding.
+type big struct {
+	A, B, C, D string
+}
+
+func uvarint(x uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, x)]
+}
+
+func TestBinaryLengthLimit(t *testing.T) {
+	const n = 1 << 16
+
+	// The length says there are n elements, and there are
+	// n bytes left, so if each element took one byte there
+	// could be enough. But each needs at least four.
+	data := append(uvarint(n), bytes.Repeat([]byte{0}, n)...)
+
+	var (
+		before, after runtime.MemStats
+		out           []big
+	)
+
+	runtime.ReadMemStats(&before)
+	err := Binary.Unmarshal(data, &out)
+	runtime.ReadMemStats(&after)
+
+	if err != ErrShortBuffer {
+		t.Fatalf("got error %v, want %v", err, ErrShortBuffer)
+	}
+
+	// Allocating the slice would take n * 64 bytes.
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > n {
+		t.Errorf("allocated %d bytes decoding %d", allocated, len(data))
+	}
+
+	// Four bytes each is just enough, though.
+	data = append(uvarint(n/4), bytes.Repeat([]byte{0}, n)...)
+
+	if err := Binary.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) != n/4 {
+		t.Errorf("got %d elements, want %d", len(out), n/4)
+	}
+}
+
+func BenchmarkSerialize(b *testing.B) {
+	for _, codec := range Codecs {
+		for _, msg := range registered() {
+			b.Run(codec.Name()+"/"+reflect.TypeOf(msg).Elem().Name(), func(b *testing.B) {
+				b.ReportAllocs()
+
+				for i := 0; i < b.N; i++ {
+					if _, err := SerializeWith(codec, msg); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkDeserialize(b *testing.B) {
+	for _, codec := range Codecs {
+		for _, msg := range registered() {
+			data, err := SerializeWith(codec, msg)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.Run(codec.Name()+"/"+reflect.TypeOf(msg).Elem().Name(), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(data)))
+
+				for i := 0; i < b.N; i++ {
+					if _, err := DeserializeWith(codec, data); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package message
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// Messages are sent over the network in frames. Each
+// frame starts with a header containing the length of
+// the frame's payload, as a big-endian uint32, which is
+// then followed by the payload itself.
+
+// HeaderSize is the size, in bytes, of a frame's header.
+const HeaderSize = 4
+
+// DefaultMaxFrameSize is the largest payload, in bytes,
+// which a FrameReader accepts unless told otherwise.
+const DefaultMaxFrameSize = 1 << 20
+
+var (
+	// ErrFrameTooLarge is returned when a frame's payload
+	// is larger than the maximum frame size.
+	ErrFrameTooLarge = errors.New("frame too large")
+
+	// ErrTruncatedFrame is returned when the stream ends
+	// part of the way through a frame.
+	ErrTruncatedFrame = errors.New("truncated frame")
+)
+
+// EncodeFrame prepends a header to a payload, making a
+// frame ready to be written.
+func EncodeFrame(payload []byte) ([]byte, error) {
+	if uint64(len(payload)) > math.MaxUint32 {
+		return nil, ErrFrameTooLarge
+	}
+
+	frame := make([]byte, HeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[HeaderSize:], payload)
+
+	return frame, nil
+}
+
+// WriteFrame writes a payload to w as a single frame.
+func WriteFrame(w io.Writer, payload []byte) error {
+	frame, err := EncodeFrame(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(frame)
+	return err
+}
+
+// A FrameReader reads frames from a stream, refusing
+// any which are larger than MaxSize. This stops a
+// peer from making it buffer an unbounded amount of
+// data.
+type FrameReader struct {
+	MaxSize int
+
+	r      io.Reader
+	header [HeaderSize]byte
+}
+
+// NewFrameReader creates a new FrameReader, reading
+// from r.
+func NewFrameReader(r io.Reader, maxSize int) *FrameReader {
+	return &FrameReader{
+		MaxSize: maxSize,
+		r:       r,
+	}
+}
+
+// ReadFrame reads the next frame and returns its payload.
+// If the stream ends cleanly between two frames, io.EOF
+// is returned. If it ends during a frame, the error is
+// ErrTruncatedFrame.
+func (f *FrameReader) ReadFrame() ([]byte, error) {
+	if _, err := io.ReadFull(f.r, f.header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncatedFrame
+		}
+
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(f.header[:])
+	if uint64(size) > uint64(f.MaxSize) {
+		return nil, ErrFrameTooLarge
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(f.r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncatedFrame
+		}
+
+		return nil, err
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,166 @@
+package message
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// testMaxFrameSize is the largest frame the tests accept.
+// It's small, so the fuzzer easily finds frames which are
+// too large.
+const testMaxFrameSize = 64
+
+// header makes a frame header for a payload of the given
+// size.
+func header(size uint32) []byte {
+	b := make([]byte, HeaderSize)
+	binary.BigEndian.PutUint32(b, size)
+
+	return b
+}
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		payload []byte
+		err     error
+	}{
+		{"empty stream", nil, nil, io.EOF},
+		{"empty payload", header(0), []byte{}, nil},
+		{"payload", append(header(3), "abc"...), []byte("abc"), nil},
+		{"truncated header", []byte{0, 0}, nil, ErrTruncatedFrame},
+		{"truncated body", append(header(5), "abc"...), nil, ErrTruncatedFrame},
+		{"missing body", header(5), nil, ErrTruncatedFrame},
+		{"largest frame", append(header(testMaxFrameSize), make([]byte, testMaxFrameSize)...), make([]byte, testMaxFrameSize), nil},
+		{"too large", header(testMaxFrameSize + 1), nil, ErrFrameTooLarge},
+		{"huge", header(0xFFFFFFFF), nil, ErrFrameTooLarge},
+	}
+
+	for _, test := range tests {
+		payload, err := NewFrameReader(bytes.NewReader(test.data), testMaxFrameSize).ReadFrame()
+
+		if err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.err)
+			continue
+		}
+
+		if err == nil && !bytes.Equal(payload, test.payload) {
+			t.Errorf("%s: got payload %q, want %q", test.name, payload, test.payload)
+		}
+	}
+}
+
+func FuzzReadFrame(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 0})
+	f.Add(append(header(1), 'x'))
+	f.Add(append(header(5), 'x'))
+	f.Add(append(append(header(1), 'x'), header(2)...))
+	f.Add(header(testMaxFrameSize + 1))
+	f.Add(header(0xFFFFFFFF))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var (
+			r    = NewFrameReader(bytes.NewReader(data), testMaxFrameSize)
+			rest = data
+		)
+
+		// Each frame is checked against the stream itself,
+		// read by hand.
+		for {
+			payload, err := r.ReadFrame()
+
+			switch {
+			case len(rest) == 0:
+				if err != io.EOF {
+					t.Fatalf("at the end of the stream, got %v", err)
+				}
+
+				return
+
+			case len(rest) < HeaderSize:
+				if err != ErrTruncatedFrame {
+					t.Fatalf("with a truncated header, got %v", err)
+				}
+
+				return
+			}
+
+			size := binary.BigEndian.Uint32(rest)
+			rest = rest[HeaderSize:]
+
+			switch {
+			case size > testMaxFrameSize:
+				if err != ErrFrameTooLarge {
+					t.Fatalf("with a %d byte frame, got %v", size, err)
+				}
+
+				return
+
+			case uint32(len(rest)) < size:
+				if err != ErrTruncatedFrame {
+					t.Fatalf("with a truncated body, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("with a valid frame, got %v", err)
+			}
+
+			if !bytes.Equal(payload, rest[:size]) {
+				t.Fatalf("got payload %q, want %q", payload, rest[:size])
+			}
+
+			rest = rest[size:]
+		}
+	})
+}
+
+func FuzzFrameRoundTrip(f *testing.F) {
+	f.Add([]byte{}, []byte("x"))
+	f.Add([]byte("hello"), []byte("world"))
+	f.Add(make([]byte, testMaxFrameSize), make([]byte, testMaxFrameSize+1))
+
+	f.Fuzz(func(t *testing.T, a, b []byte) {
+		var buf bytes.Buffer
+
+		for _, payload := range [][]byte{a, b} {
+			if err := WriteFrame(&buf, payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r := NewFrameReader(&buf, testMaxFrameSize)
+
+		for _, want := range [][]byte{a, b} {
+			got, err := r.ReadFrame()
+
+			if len(want) > testMaxFrameSize {
+				if err != ErrFrameTooLarge {
+					t.Fatalf("with a %d byte frame, got %v", len(want), err)
+				}
+
+				// The rest of the stream can't be trusted
+				// after a frame which is too large.
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		}
+
+		if _, err := r.ReadFrame(); err != io.EOF {
+			t.Fatalf("after the last frame, got %v", err)
+		}
+	})
+}
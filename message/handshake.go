@@ -0,0 +1,71 @@
+package message
+
+// This file contains the messages used in the
+// handshake, which happens before a client is
+// allowed to join the game.
+//
+// The client starts by sending a ClientInfo. The
+// server then replies with either Accepted, after
+// which the game begins, or Rejected, after which
+// the connection is closed.
+//
+// The handshake is always encoded as JSON. Every
+// message after it uses the codec chosen in the
+// Accepted message.
+
+func init() {
+	Register(1, ClientToServer, ClientInfo{})
+	Register(2, ServerToClient, Accepted{})
+	Register(3, ServerToClient, Rejected{})
+}
+
+// ProtocolVersion is the version of the protocol
+// spoken by this build. It must be increased
+// whenever a message changes in a way which older
+// builds wouldn't understand.
+const ProtocolVersion = 11
+
+// Capabilities is the list of optional protocol
+// features supported by this build.
+var Capabilities = []string{}
+
+// Accepted tells a client that the handshake
+// succeeded. Capabilities contains the optional
+// features which both sides support, and Codec
+// is the name of the codec used from now on.
+type Accepted struct {
+	Capabilities []string
+	Codec        string
+}
+
+// Rejected tells a client that it can't join
+// the game, and why.
+type Rejected struct {
+	Reason string
+}
+
+// CommonCapabilities returns the capabilities
+// from theirs which this build also supports.
+func CommonCapabilities(theirs []string) []string {
+	common := []string{}
+
+	for _, c := range theirs {
+		if HasCapability(Capabilities, c) {
+			common = append(common, c)
+		}
+	}
+
+	return common
+}
+
+// HasCapability checks whether a capability is
+// in a list of capabilities.
+func HasCapability(caps []string, c string) bool {
+	for _, other := range caps {
+		if other == c {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package message
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// An ID identifies a type of message. It's sent before
+// the message's body, so the receiver knows what to
+// decode it into. IDs must never be changed or reused,
+// or different builds would disagree on what they mean.
+type ID uint8
+
+// A Direction says which way a message can be sent.
+type Direction int
+
+const (
+	// ClientToServer messages are sent by clients.
+	ClientToServer Direction = 1 << iota
+
+	// ServerToClient messages are sent by the server.
+	ServerToClient
+
+	// Both means a message can be sent either way.
+	Both = ClientToServer | ServerToClient
+)
+
+func (d Direction) String() string {
+	switch d {
+	case ClientToServer:
+		return "client-to-server"
+	case ServerToClient:
+		return "server-to-client"
+	case Both:
+		return "both"
+	default:
+		return fmt.Sprintf("Direction(%d)", int(d))
+	}
+}
+
+// A registration stores what's known about a type
+// of message.
+type registration struct {
+	id  ID
+	dir Direction
+	typ reflect.Type
+}
+
+var (
+	byID   = make(map[ID]*registration)
+	byType = make(map[reflect.Type]*registration)
+)
+
+// Register adds a type of message to the registry, so
+// it can be serialized. The template is any value of the
+// message's type, or a pointer to one. It panics if the
+// ID or the type has already been registered, since that
+// can only be a programming error.
+func Register(id ID, dir Direction, template interface{}) {
+	typ := reflect.TypeOf(template)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if other, ok := byID[id]; ok {
+		panic(fmt.Sprintf("message: id %d is used by both %s and %s", id, other.typ, typ))
+	}
+
+	if _, ok := byType[typ]; ok {
+		panic(fmt.Sprintf("message: %s is registered twice", typ))
+	}
+
+	reg := &registration{
+		id:  id,
+		dir: dir,
+		typ: typ,
+	}
+
+	byID[id] = reg
+	byType[typ] = reg
+}
+
+// lookup finds the registration for a message, which
+// can be given either as a value or a pointer.
+func lookup(msg interface{}) (*registration, bool) {
+	if msg == nil {
+		return nil, false
+	}
+
+	typ := reflect.TypeOf(msg)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	reg, ok := byType[typ]
+	return reg, ok
+}
+
+// IDOf returns the ID of a message, and false if its
+// type isn't registered.
+func IDOf(msg interface{}) (ID, bool) {
+	reg, ok := lookup(msg)
+	if !ok {
+		return 0, false
+	}
+
+	return reg.id, true
+}
+
+// Allowed checks whether a message can be sent in the
+// given direction.
+func Allowed(msg interface{}, dir Direction) bool {
+	reg, ok := lookup(msg)
+	return ok && reg.dir&dir == dir
+}
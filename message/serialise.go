@@ -1,84 +1,58 @@
 package message
 
 import (
-	"encoding/json"
 	"fmt"
 	"reflect"
 )
 
 // Serialize serializes a message by marshalling
-// it into JSON, then prepending a prefix unique
-// to the type of message.
+// it into JSON, then prepending its ID.
 func Serialize(msg interface{}) ([]byte, error) {
-	var prefix byte
-
-	switch msg.(type) {
-	case *GameInfo, GameInfo:
-		prefix = 'g'
-	case *PlayerMoved, PlayerMoved:
-		prefix = 'p'
-	case *NewPlayer, NewPlayer:
-		prefix = 'n'
-	case *PlayerLeft, PlayerLeft:
-		prefix = 'l'
-
-	case *ClientInfo, ClientInfo:
-		prefix = 'c'
-	case *Moved, Moved:
-		prefix = 'm'
-	case *Disconnect, Disconnect:
-		prefix = 'x'
-	case *StateUpdate, StateUpdate:
-		prefix = 's'
-	case *ChatMessage, ChatMessage:
-		prefix = 't'
+	return SerializeWith(JSON, msg)
+}
 
-	default:
-		return []byte{}, fmt.Errorf("invalid message type: %s", reflect.TypeOf(msg).String())
+// SerializeWith serializes a message by marshalling
+// it with the given codec, then prepending the ID
+// it was registered with.
+func SerializeWith(codec Codec, msg interface{}) ([]byte, error) {
+	reg, ok := lookup(msg)
+	if !ok {
+		return []byte{}, fmt.Errorf("invalid message type: %s", reflect.TypeOf(msg))
 	}
 
-	bytes, err := json.Marshal(msg)
+	bytes, err := codec.Marshal(msg)
 	if err != nil {
 		return []byte{}, err
 	}
 
-	bytes = append([]byte{prefix}, bytes...)
+	bytes = append([]byte{byte(reg.id)}, bytes...)
 
 	return bytes, nil
 }
 
 // Deserialize does the opposite of Serialize:
 // takes some JSON data with an appropriate
-// prefix, and returns the message.
+// ID, and returns the message.
 func Deserialize(data []byte) (interface{}, error) {
-	var template interface{}
-
-	switch data[0] {
-	case 'g':
-		template = &GameInfo{}
-	case 'p':
-		template = &PlayerMoved{}
-	case 'n':
-		template = &NewPlayer{}
-	case 'l':
-		template = &PlayerLeft{}
+	return DeserializeWith(JSON, data)
+}
 
-	case 'c':
-		template = &ClientInfo{}
-	case 'm':
-		template = &Moved{}
-	case 'x':
-		template = &Disconnect{}
-	case 's':
-		template = &StateUpdate{}
-	case 't':
-		template = &ChatMessage{}
+// DeserializeWith does the opposite of
+// SerializeWith, using the given codec. The
+// message is always returned as a pointer.
+func DeserializeWith(codec Codec, data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty message")
+	}
 
-	default:
-		return nil, fmt.Errorf("invalid message prefix: %s", string(data[0]))
+	reg, ok := byID[ID(data[0])]
+	if !ok {
+		return nil, fmt.Errorf("invalid message id: %d", data[0])
 	}
 
-	if err := json.Unmarshal(data[1:], template); err != nil {
+	template := reflect.New(reg.typ).Interface()
+
+	if err := codec.Unmarshal(data[1:], template); err != nil {
 		return nil, err
 	}
 
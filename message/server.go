@@ -9,23 +9,47 @@ import (
 // This file contains messages which the server
 // can send to the client.
 
+func init() {
+	Register(20, ServerToClient, GameInfo{})
+	Register(21, ServerToClient, WorldChunk{})
+	Register(22, ServerToClient, NewPlayer{})
+	Register(23, ServerToClient, PlayerLeft{})
+	Register(24, ServerToClient, Snapshot{})
+	Register(25, ServerToClient, Correction{})
+}
+
 // An AbstractPlayer is a slightly compressed
 // struct which can be expanded again to create
-// an entity.Ship.
+// an entity.Ship. Seq is the last of the
+// player's moves which the server has processed.
 type AbstractPlayer struct {
 	Position    geom.Coord
+	ApparentPos geom.Vector
 	Destination geom.Coord
+	Seq         uint32
 }
 
 // GameInfo is the information initially sent
-// to a new client.
+// to a new client. The world's tiles aren't
+// included, but are sent afterwards as a
+// WorldChunk for each chunk in the world.
+// Seed is the seed the world was generated
+// from.
 type GameInfo struct {
-	Tiles   [world.Height][world.Width]world.Tile
-	Players map[uuid.UUID]AbstractPlayer
+	Width, Height int
+	Seed          int64
+	Players       map[uuid.UUID]AbstractPlayer
 
 	ID uuid.UUID // The UUID of the receiving client
 }
 
+// A WorldChunk contains the tiles in one chunk
+// of the world, encoded by world.EncodeChunk.
+type WorldChunk struct {
+	Chunk world.ChunkCoord
+	Data  []byte
+}
+
 // NewPlayer tells existing players that a
 // new client has joined.
 type NewPlayer struct {
@@ -39,9 +63,16 @@ type PlayerLeft struct {
 	ID uuid.UUID
 }
 
-// PlayerMoved tells a client that a client
-// has moved, and where he moved to.
-type PlayerMoved struct {
-	ID       uuid.UUID
-	Position geom.Coord
+// A Snapshot is broadcast regularly by the server,
+// containing the authoritative state of every ship.
+type Snapshot struct {
+	Tick    uint64
+	Players map[uuid.UUID]AbstractPlayer
+}
+
+// A Correction tells a client that the position it
+// reported disagrees with the server's, and where its
+// ship actually is.
+type Correction struct {
+	Player AbstractPlayer
 }
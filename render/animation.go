@@ -0,0 +1,53 @@
+package render
+
+import "github.com/Zac-Garby/pieces-of-seven/world"
+
+// animationStep returns how many frames of a tile's
+// animation have gone by after the given amount of
+// seconds.
+func animationStep(data *world.TileData, time float64) int64 {
+	if data.Frames <= 1 || data.FrameTime <= 0 {
+		return 0
+	}
+
+	return int64(time / data.FrameTime)
+}
+
+// tileFrame returns which frame of its animation the tile
+// at (x, y) is on, after step frames have gone by. Tiles
+// which don't animate are always on the first frame.
+func tileFrame(data *world.TileData, x, y int, step int64) int32 {
+	if data.Frames <= 1 || data.FrameTime <= 0 {
+		return 0
+	}
+
+	if data.Phase {
+		step += int64(phase(x, y))
+	}
+
+	return int32(step % int64(data.Frames))
+}
+
+// phase hashes a coordinate into a number, which is the
+// same every time for the same coordinate but looks
+// random from one tile to the next.
+func phase(x, y int) uint32 {
+	h := uint32(x)*374761393 + uint32(y)*668265263
+	h = (h ^ h>>13) * 1274126177
+
+	return (h ^ h>>16) & 0xFFFF
+}
+
+// frameWidth returns how many tiles wide each frame of a
+// texture is.
+func frameWidth(a world.Autotile) int32 {
+	switch a {
+	case world.Corners:
+		return 4
+
+	case world.Blob:
+		return 8
+	}
+
+	return 1
+}
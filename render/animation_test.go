@@ -0,0 +1,82 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/world"
+)
+
+func TestAnimationStep(t *testing.T) {
+	tests := []struct {
+		data world.TileData
+		time float64
+		want int64
+	}{
+		{world.TileData{Frames: 5, FrameTime: 0.5}, 0, 0},
+		{world.TileData{Frames: 5, FrameTime: 0.5}, 0.4, 0},
+		{world.TileData{Frames: 5, FrameTime: 0.5}, 0.6, 1},
+		{world.TileData{Frames: 5, FrameTime: 0.5}, 3.2, 6},
+		{world.TileData{Frames: 1, FrameTime: 0.5}, 3.2, 0},
+		{world.TileData{Frames: 0, FrameTime: 0.5}, 3.2, 0},
+		{world.TileData{Frames: 5, FrameTime: 0}, 3.2, 0},
+		{world.TileData{Frames: 5, FrameTime: -1}, 3.2, 0},
+	}
+
+	for _, test := range tests {
+		if got := animationStep(&test.data, test.time); got != test.want {
+			t.Errorf("%d frames of %vs: expected step %d after %vs, got %d", test.data.Frames, test.data.FrameTime, test.want, test.time, got)
+		}
+	}
+}
+
+func TestTileFrame(t *testing.T) {
+	data := &world.TileData{Frames: 5, FrameTime: 0.5}
+
+	// The frames wrap round once they get to Frames.
+	for step := int64(0); step < 12; step++ {
+		if got, want := tileFrame(data, 3, 4, step), int32(step%5); got != want {
+			t.Errorf("expected frame %d after %d steps, got %d", want, step, got)
+		}
+	}
+
+	still := []world.TileData{
+		{Frames: 1, FrameTime: 0.5, Phase: true},
+		{Frames: 0, FrameTime: 0.5, Phase: true},
+		{Frames: 5, FrameTime: 0, Phase: true},
+		{Frames: 5, FrameTime: -1, Phase: true},
+	}
+
+	for _, data := range still {
+		for x := 0; x < 10; x++ {
+			if got := tileFrame(&data, x, 0, 7); got != 0 {
+				t.Errorf("%d frames of %vs: expected frame 0 at (%d, 0), got %d", data.Frames, data.FrameTime, x, got)
+			}
+		}
+	}
+}
+
+func TestTilePhase(t *testing.T) {
+	var (
+		data   = &world.TileData{Frames: 5, FrameTime: 0.5, Phase: true}
+		frames = make(map[int32]bool)
+	)
+
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if phase(x, y) == phase(x+1, y) || phase(x, y) == phase(x, y+1) {
+				t.Errorf("expected (%d, %d) to have a different phase to the tiles right of and below it", x, y)
+			}
+
+			frames[tileFrame(data, x, y, 0)] = true
+		}
+	}
+
+	if len(frames) != 5 {
+		t.Errorf("expected tiles to start on all 5 frames, got %d", len(frames))
+	}
+
+	// Each tile still moves on one frame at a time.
+	if a, b := tileFrame(data, 2, 7, 0), tileFrame(data, 2, 7, 1); b != (a+1)%5 {
+		t.Errorf("expected the frame after %d to be %d, got %d", a, (a+1)%5, b)
+	}
+}
@@ -0,0 +1,182 @@
+package render
+
+import (
+	"sort"
+
+	"github.com/Zac-Garby/pieces-of-seven/world"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// The bits of a blob tile's neighbour mask.
+const (
+	blobN = 1 << iota
+	blobNE
+	blobE
+	blobSE
+	blobS
+	blobSW
+	blobW
+	blobNW
+)
+
+// blobIndices maps each of the 256 neighbour masks to
+// the index of its tile in a blob texture. The tiles are
+// in order of their reduced masks, smallest first.
+var blobIndices = makeBlobIndices()
+
+func makeBlobIndices() [256]int32 {
+	var (
+		indices [256]int32
+		masks   []int
+		seen    = make(map[int]bool)
+	)
+
+	for mask := 0; mask < 256; mask++ {
+		if r := reduceBlobMask(mask); !seen[r] {
+			seen[r] = true
+			masks = append(masks, r)
+		}
+	}
+
+	sort.Ints(masks)
+
+	for mask := 0; mask < 256; mask++ {
+		indices[mask] = int32(sort.SearchInts(masks, reduceBlobMask(mask)))
+	}
+
+	return indices
+}
+
+// reduceBlobMask clears the diagonal bits of a mask which
+// don't have both of the edges next to them set, since
+// they don't change what the tile looks like.
+func reduceBlobMask(mask int) int {
+	corners := []struct{ corner, a, b int }{
+		{blobNE, blobN, blobE},
+		{blobSE, blobS, blobE},
+		{blobSW, blobS, blobW},
+		{blobNW, blobN, blobW},
+	}
+
+	for _, c := range corners {
+		if mask&c.a == 0 || mask&c.b == 0 {
+			mask &^= c.corner
+		}
+	}
+
+	return mask
+}
+
+// tileAt returns the tile at (x, y). Coordinates outside
+// the world are moved to the nearest edge, so tiles carry
+// on past it.
+func tileAt(w *world.World, x, y int) world.Tile {
+	x = clamp(x, 0, w.Width-1)
+	y = clamp(y, 0, w.Height-1)
+
+	return w.Tiles[y][x]
+}
+
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+
+	if n > max {
+		return max
+	}
+
+	return n
+}
+
+// cornerBits returns which corners of (x, y) touch a tile
+// of type t. Bit 3 is the top-left corner, then they go
+// clockwise.
+func cornerBits(w *world.World, t world.Tile, x, y int) int {
+	var (
+		bits    = 0
+		corners = []struct{ dx, dy, bits int }{
+			{-1, -1, 1 << 3},
+			{0, -1, 1<<3 | 1<<2},
+			{1, -1, 1 << 2},
+			{-1, 0, 1<<3 | 1<<0},
+			{0, 0, 0xF},
+			{1, 0, 1<<2 | 1<<1},
+			{-1, 1, 1 << 0},
+			{0, 1, 1<<0 | 1<<1},
+			{1, 1, 1 << 1},
+		}
+	)
+
+	for _, c := range corners {
+		if tileAt(w, x+c.dx, y+c.dy) == t {
+			bits |= c.bits
+		}
+	}
+
+	return bits
+}
+
+// blobMask returns which of the 8 neighbours of (x, y)
+// are the same tile as it.
+func blobMask(w *world.World, x, y int) int {
+	var (
+		t         = w.Tiles[y][x]
+		mask      = 0
+		neighbors = []struct{ dx, dy, bit int }{
+			{0, -1, blobN},
+			{1, -1, blobNE},
+			{1, 0, blobE},
+			{1, 1, blobSE},
+			{0, 1, blobS},
+			{-1, 1, blobSW},
+			{-1, 0, blobW},
+			{-1, -1, blobNW},
+		}
+	)
+
+	for _, n := range neighbors {
+		if tileAt(w, x+n.dx, y+n.dy) == t {
+			mask |= n.bit
+		}
+	}
+
+	return mask
+}
+
+// autotileRect returns the part of a tile's texture to
+// draw at (x, y), or false if nothing of the tile is
+// drawn there.
+func autotileRect(w *world.World, t world.Tile, x, y int) (sdl.Rect, bool) {
+	switch t.GetData().Autotile {
+	case world.Corners:
+		bits := int32(cornerBits(w, t, x, y))
+		if bits == 0 {
+			return sdl.Rect{}, false
+		}
+
+		return sourceRect(bits%4, bits/4), true
+
+	case world.Blob:
+		if w.Tiles[y][x] != t {
+			return sdl.Rect{}, false
+		}
+
+		index := blobIndices[blobMask(w, x, y)]
+
+		return sourceRect(index%8, index/8), true
+	}
+
+	return sourceRect(0, 0), w.Tiles[y][x] == t
+}
+
+// sourceRect returns the rect of the tile in the given
+// column and row of a texture.
+func sourceRect(col, row int32) sdl.Rect {
+	return sdl.Rect{
+		X: col * SourceTileSize,
+		Y: row * SourceTileSize,
+		W: SourceTileSize,
+		H: SourceTileSize,
+	}
+}
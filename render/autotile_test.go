@@ -0,0 +1,180 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/world"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// craftWorld makes a World from rows of characters, one
+// for each tile:
+//
+//	. water
+//	s shallow water
+//	# land
+//	r rock
+func craftWorld(rows ...string) *world.World {
+	w := world.New(len(rows[0]), len(rows))
+
+	tiles := map[rune]world.Tile{
+		'.': world.Water,
+		's': world.ShallowWater,
+		'#': world.Land,
+		'r': world.Rock,
+	}
+
+	for y, row := range rows {
+		for x, c := range row {
+			w.Tiles[y][x] = tiles[c]
+		}
+	}
+
+	return w
+}
+
+func TestBlobIndices(t *testing.T) {
+	seen := make(map[int32]bool)
+
+	for mask, index := range blobIndices {
+		if index < 0 || index >= 47 {
+			t.Errorf("mask %08b has index %d, outside the 47 tiles", mask, index)
+		}
+
+		if want := blobIndices[reduceBlobMask(mask)]; index != want {
+			t.Errorf("mask %08b has index %d, but its reduced mask has %d", mask, index, want)
+		}
+
+		seen[index] = true
+	}
+
+	if len(seen) != 47 {
+		t.Errorf("expected 47 different tiles, got %d", len(seen))
+	}
+
+	// The tiles are in order of their masks, so the tile
+	// with no neighbours is first and the one with all of
+	// them is last.
+	if blobIndices[0] != 0 || blobIndices[0xFF] != 46 {
+		t.Errorf("expected the first and last tiles to be 0 and 46, got %d and %d", blobIndices[0], blobIndices[0xFF])
+	}
+}
+
+func TestReduceBlobMask(t *testing.T) {
+	tests := []struct {
+		mask, want int
+	}{
+		{0, 0},
+		{0xFF, 0xFF},
+		{blobNE, 0},
+		{blobNE | blobSE | blobSW | blobNW, 0},
+		{blobN | blobNE, blobN},
+		{blobE | blobNE, blobE},
+		{blobN | blobE | blobNE, blobN | blobE | blobNE},
+		{blobS | blobW | blobSW | blobSE, blobS | blobW | blobSW},
+		{blobN | blobE | blobS | blobW, blobN | blobE | blobS | blobW},
+		{blobN | blobE | blobS | blobNE | blobSE | blobSW | blobNW, blobN | blobE | blobS | blobNE | blobSE},
+	}
+
+	for _, test := range tests {
+		if got := reduceBlobMask(test.mask); got != test.want {
+			t.Errorf("expected %08b to reduce to %08b, got %08b", test.mask, test.want, got)
+		}
+	}
+}
+
+func TestCornerBits(t *testing.T) {
+	tests := []struct {
+		rows []string
+		want [3][3]int
+	}{
+		{
+			rows: []string{
+				"...",
+				".#.",
+				"...",
+			},
+			want: [3][3]int{
+				{0x2, 0x3, 0x1},
+				{0x6, 0xF, 0x9},
+				{0x4, 0xC, 0x8},
+			},
+		},
+
+		// Tiles past the edge of the world are the same as
+		// the ones on the edge, so the land carries on to
+		// the left.
+		{
+			rows: []string{
+				"#..",
+				"#..",
+				"#..",
+			},
+			want: [3][3]int{
+				{0xF, 0x9, 0x0},
+				{0xF, 0x9, 0x0},
+				{0xF, 0x9, 0x0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		w := craftWorld(test.rows...)
+
+		for y, row := range test.want {
+			for x, want := range row {
+				if got := cornerBits(w, world.Land, x, y); got != want {
+					t.Errorf("%v: expected (%d, %d) to have corners %04b, got %04b", test.rows, x, y, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestAutotileRect(t *testing.T) {
+	var (
+		corners = craftWorld(
+			"...",
+			".#.",
+			"...",
+		)
+
+		blob = craftWorld(
+			"rr.",
+			"rr.",
+			"...",
+		)
+
+		// The tile at (1, 1) in blob has rock above, to
+		// the left, and diagonally between them.
+		inner = blobIndices[blobN|blobW|blobNW]
+	)
+
+	tests := []struct {
+		name string
+		w    *world.World
+		tile world.Tile
+		x, y int
+		rect sdl.Rect
+		ok   bool
+	}{
+		{"land in the middle", corners, world.Land, 1, 1, sourceRect(3, 3), true},
+		{"land to the bottom right", corners, world.Land, 0, 0, sourceRect(2, 0), true},
+		{"land above", corners, world.Land, 1, 2, sourceRect(0, 3), true},
+		{"no land nearby", craftWorld("...", "...", "..."), world.Land, 1, 1, sdl.Rect{}, false},
+		{"rock all around", blob, world.Rock, 0, 0, sourceRect(6, 5), true},
+		{"rock above and left", blob, world.Rock, 1, 1, sourceRect(inner%8, inner/8), true},
+		{"rock on its own", craftWorld("...", ".r.", "..."), world.Rock, 1, 1, sourceRect(0, 0), true},
+		{"not rock", blob, world.Rock, 2, 2, sdl.Rect{}, false},
+		{"water", corners, world.Water, 0, 0, sourceRect(0, 0), true},
+		{"not water", corners, world.Water, 1, 1, sourceRect(0, 0), false},
+	}
+
+	for _, test := range tests {
+		rect, ok := autotileRect(test.w, test.tile, test.x, test.y)
+
+		if ok != test.ok || rect != test.rect {
+			t.Errorf("%s: expected %v (%v), got %v (%v)", test.name, test.rect, test.ok, rect, ok)
+		}
+	}
+}
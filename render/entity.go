@@ -0,0 +1,74 @@
+package render
+
+import (
+	"github.com/Zac-Garby/pieces-of-seven/entity"
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+	"github.com/Zac-Garby/pieces-of-seven/loader"
+	"github.com/Zac-Garby/pieces-of-seven/world"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Entity renders any kind of entity on the given
+// renderer.
+func Entity(e entity.Entity, viewOffset *geom.Vector, ld *loader.Loader, rend *sdl.Renderer) {
+	switch e := e.(type) {
+	case *entity.Ship:
+		Ship(e, viewOffset, ld, rend)
+	}
+}
+
+// Ship renders a ship on the given renderer.
+func Ship(s *entity.Ship, viewOffset *geom.Vector, ld *loader.Loader, rend *sdl.Renderer) {
+	rend.SetDrawColor(255, 0, 0, 255)
+
+	tex := ld.Textures["ship"]
+
+	src := shipSheetRect(s.Direction())
+
+	dst := &sdl.Rect{
+		X: int32(s.ApparentPos.X*world.TileSize) - int32(viewOffset.X),
+		Y: int32(s.ApparentPos.Y*world.TileSize) - int32(viewOffset.Y),
+		W: int32(world.TileSize),
+		H: int32(world.TileSize),
+	}
+
+	rend.Copy(tex, src, dst)
+}
+
+// shipSheetRect returns the part of the ship's sprite
+// sheet which shows it facing the given direction.
+func shipSheetRect(direction uint8) *sdl.Rect {
+	var (
+		x = 0
+		y = 0
+	)
+
+	switch direction {
+	case 0:
+		x = 3
+		y = 1
+	case 1:
+		break
+	case 2:
+		y = 1
+	case 3:
+		x = 1
+	case 4:
+		x = 1
+		y = 1
+	case 5:
+		x = 2
+	case 6:
+		x = 2
+		y = 1
+	case 7:
+		x = 3
+	}
+
+	return &sdl.Rect{
+		X: int32(x * 15),
+		Y: int32(y * 15),
+		W: 15,
+		H: 15,
+	}
+}
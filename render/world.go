@@ -0,0 +1,368 @@
+// Package render draws the game's worlds and entities
+// with SDL. It's only used by the client, so the
+// simulation packages, and the server, don't need SDL.
+package render
+
+import (
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+	"github.com/Zac-Garby/pieces-of-seven/loader"
+	"github.com/Zac-Garby/pieces-of-seven/world"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// SourceTileSize is the size, in pixels, of each tile in
+// the tile textures.
+const SourceTileSize = 15
+
+// MaxBakedChunks is the amount of chunks a WorldRenderer
+// keeps baked. When there are more, the ones which were
+// drawn least recently are thrown away.
+const MaxBakedChunks = 128
+
+// A WorldRenderer renders Worlds, keeping track of how
+// far through their animations their tiles are.
+//
+// Rather than drawing every tile every frame, each chunk
+// of the World is baked into its own texture the first
+// time it's drawn, and the textures are drawn instead.
+// A chunk is only baked again when one of its tiles, or
+// a neighbouring one, changes, or when it has animated
+// tiles and one of their animations moves on.
+type WorldRenderer struct {
+	time float64
+
+	// drawTiles is true if every tile is drawn every frame
+	// instead of baking chunks, which is only useful to
+	// compare how long each way takes.
+	drawTiles bool
+
+	world  *world.World
+	chunks map[world.ChunkCoord]*bakedChunk
+	draws  uint64
+}
+
+// A bakedChunk is a chunk of the World which has been
+// drawn to a texture.
+type bakedChunk struct {
+	tex *sdl.Texture
+
+	// version is the sum of the versions of the chunk and
+	// its neighbours when it was baked, since the edges of
+	// marching squares tiles depend on their neighbours.
+	version uint64
+
+	// animated is the animated tiles drawn in the chunk,
+	// and steps is how far through their animations they
+	// were when it was baked.
+	animated []world.Tile
+	steps    int64
+
+	// drawn is when the chunk was last drawn.
+	drawn uint64
+}
+
+// NewWorldRenderer creates a new WorldRenderer.
+func NewWorldRenderer() *WorldRenderer {
+	return &WorldRenderer{
+		chunks: make(map[world.ChunkCoord]*bakedChunk),
+	}
+}
+
+// Update moves the animations on by dt seconds.
+func (r *WorldRenderer) Update(dt float64) {
+	r.time += dt
+}
+
+// steps combines how far each of the given tiles is
+// through its animation, so it changes whenever any of
+// them moves on to its next frame.
+func (r *WorldRenderer) steps(tiles []world.Tile) int64 {
+	var steps int64
+
+	for _, tile := range tiles {
+		steps = steps*31 + animationStep(tile.GetData(), r.time)
+	}
+
+	return steps
+}
+
+// Free destroys every baked chunk's texture.
+func (r *WorldRenderer) Free() {
+	for c, chunk := range r.chunks {
+		chunk.tex.Destroy()
+		delete(r.chunks, c)
+	}
+}
+
+// Render renders the world to the given
+// SDL renderer.
+func (r *WorldRenderer) Render(w *world.World, rend *sdl.Renderer, ld *loader.Loader, viewOffset *geom.Vector, width, height int) {
+	if r.drawTiles {
+		r.renderTiles(w, rend, ld, viewOffset, width, height)
+		r.renderUnloaded(w, rend, viewOffset, width, height)
+
+		return
+	}
+
+	// Chunks from a different World are no use.
+	if w != r.world {
+		r.Free()
+		r.world = w
+	}
+
+	r.draws++
+
+	var (
+		size   = world.ChunkSize * world.TileSize
+		startX = int(viewOffset.X) / size
+		startY = int(viewOffset.Y) / size
+		endX   = (int(viewOffset.X) + width) / size
+		endY   = (int(viewOffset.Y) + height) / size
+	)
+
+	for cy := startY; cy <= endY; cy++ {
+		for cx := startX; cx <= endX; cx++ {
+			c := world.ChunkCoord{X: cx, Y: cy}
+
+			if !w.ValidChunk(c) || !w.IsLoaded(cx*world.ChunkSize, cy*world.ChunkSize) {
+				continue
+			}
+
+			chunk, err := r.chunk(w, c, rend, ld)
+			if err != nil {
+				continue
+			}
+
+			x0, y0, x1, y1 := w.ChunkBounds(c)
+
+			rend.Copy(chunk.tex, nil, &sdl.Rect{
+				X: int32(x0*world.TileSize) - int32(viewOffset.X),
+				Y: int32(y0*world.TileSize) - int32(viewOffset.Y),
+				W: int32((x1 - x0) * world.TileSize),
+				H: int32((y1 - y0) * world.TileSize),
+			})
+		}
+	}
+
+	r.evict()
+	r.renderUnloaded(w, rend, viewOffset, width, height)
+}
+
+// chunk returns a chunk's baked texture, baking it if it
+// hasn't been baked yet or is out of date.
+func (r *WorldRenderer) chunk(w *world.World, c world.ChunkCoord, rend *sdl.Renderer, ld *loader.Loader) (*bakedChunk, error) {
+	var version uint64
+
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			version += w.ChunkVersion(world.ChunkCoord{X: c.X + dx, Y: c.Y + dy})
+		}
+	}
+
+	chunk, ok := r.chunks[c]
+
+	if !ok {
+		x0, y0, x1, y1 := w.ChunkBounds(c)
+
+		tex, err := rend.CreateTexture(
+			sdl.PIXELFORMAT_RGBA8888,
+			sdl.TEXTUREACCESS_TARGET,
+			int32((x1-x0)*SourceTileSize),
+			int32((y1-y0)*SourceTileSize),
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		chunk = &bakedChunk{tex: tex}
+		r.chunks[c] = chunk
+	}
+
+	// Only the animations of the tiles in the chunk matter,
+	// so a chunk without any is never baked again because
+	// of them.
+	if !ok || chunk.version != version || chunk.steps != r.steps(chunk.animated) {
+		r.bake(w, c, chunk, rend, ld)
+
+		chunk.version = version
+		chunk.steps = r.steps(chunk.animated)
+	}
+
+	chunk.drawn = r.draws
+
+	return chunk, nil
+}
+
+// bake draws a chunk's tiles onto its texture.
+func (r *WorldRenderer) bake(w *world.World, c world.ChunkCoord, chunk *bakedChunk, rend *sdl.Renderer, ld *loader.Loader) {
+	x0, y0, x1, y1 := w.ChunkBounds(c)
+
+	rend.SetRenderTarget(chunk.tex)
+	defer rend.SetRenderTarget(nil)
+
+	rend.SetDrawColor(0, 0, 0, 255)
+	rend.Clear()
+
+	chunk.animated = chunk.animated[:0]
+
+	for _, tile := range world.Tiles {
+		data := tile.GetData()
+
+		draws := tileDraws(w, tile, x0, y0, x1, y1, int32(x0*SourceTileSize), int32(y0*SourceTileSize), SourceTileSize, animationStep(data, r.time))
+
+		if len(draws) > 0 && data.Frames > 1 {
+			chunk.animated = append(chunk.animated, tile)
+		}
+
+		for _, d := range draws {
+			rend.Copy(ld.Textures[d.texture], &d.src, &d.dest)
+		}
+	}
+}
+
+// evict throws away the chunks which were drawn least
+// recently, until there are at most MaxBakedChunks.
+func (r *WorldRenderer) evict() {
+	for len(r.chunks) > MaxBakedChunks {
+		var (
+			oldest world.ChunkCoord
+			drawn  = r.draws + 1
+		)
+
+		for c, chunk := range r.chunks {
+			if chunk.drawn < drawn {
+				oldest, drawn = c, chunk.drawn
+			}
+		}
+
+		r.chunks[oldest].tex.Destroy()
+		delete(r.chunks, oldest)
+	}
+}
+
+// renderTiles draws every visible tile straight to the
+// screen, without baking any chunks.
+func (r *WorldRenderer) renderTiles(w *world.World, rend *sdl.Renderer, ld *loader.Loader, viewOffset *geom.Vector, width, height int) {
+	// Calculate the amount of visible tiles, with some
+	// padding on the side just in case
+	var (
+		startX = int(viewOffset.X)/world.TileSize - 1
+		startY = int(viewOffset.Y)/world.TileSize - 1
+		endX   = startX + width/world.TileSize + 3
+		endY   = startY + height/world.TileSize + 3
+	)
+
+	for _, tile := range world.Tiles {
+		draws := tileDraws(w, tile, startX, startY, endX, endY, int32(viewOffset.X), int32(viewOffset.Y), world.TileSize, animationStep(tile.GetData(), r.time))
+
+		for _, d := range draws {
+			rend.Copy(ld.Textures[d.texture], &d.src, &d.dest)
+		}
+	}
+}
+
+// renderUnloaded covers the visible chunks which haven't
+// been received yet, so it's clear they're still loading.
+func (r *WorldRenderer) renderUnloaded(w *world.World, rend *sdl.Renderer, viewOffset *geom.Vector, width, height int) {
+	var (
+		size   = world.ChunkSize * world.TileSize
+		startX = int(viewOffset.X) / size
+		startY = int(viewOffset.Y) / size
+		endX   = (int(viewOffset.X) + width) / size
+		endY   = (int(viewOffset.Y) + height) / size
+
+		// The indicator pulses, so it looks like something
+		// is happening.
+		shade = uint8(30 + int(r.time*5)%10*3)
+	)
+
+	for cy := startY; cy <= endY; cy++ {
+		for cx := startX; cx <= endX; cx++ {
+			c := world.ChunkCoord{X: cx, Y: cy}
+
+			if !w.ValidChunk(c) || w.IsLoaded(cx*world.ChunkSize, cy*world.ChunkSize) {
+				continue
+			}
+
+			rect := &sdl.Rect{
+				X: int32(cx*size) - int32(viewOffset.X),
+				Y: int32(cy*size) - int32(viewOffset.Y),
+				W: int32(size),
+				H: int32(size),
+			}
+
+			rend.SetDrawColor(shade, shade, shade+10, 255)
+			rend.FillRect(rect)
+
+			rend.SetDrawColor(60, 60, 70, 255)
+			rend.DrawRect(rect)
+		}
+	}
+}
+
+// A tileDraw is a part of a texture to draw, and where
+// to draw it.
+type tileDraw struct {
+	texture   string
+	src, dest sdl.Rect
+}
+
+// tileDraws returns what to draw for the tiles of type t
+// between (x0, y0) and (x1, y1), not including the
+// latter. Each tile is drawn size pixels across, offset
+// by (offsetX, offsetY), after step frames of the tile's
+// animation have gone by.
+func tileDraws(w *world.World, t world.Tile, x0, y0, x1, y1 int, offsetX, offsetY, size int32, step int64) []tileDraw {
+	var (
+		data  = t.GetData()
+		draws = []tileDraw{}
+	)
+
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if !w.InBounds(x, y) {
+				continue
+			}
+
+			var (
+				src sdl.Rect
+				ok  bool
+			)
+
+			// Water is drawn under everything else, so
+			// there's never a gap.
+			if t == world.Water {
+				src, ok = sourceRect(0, 0), true
+			} else {
+				src, ok = autotileRect(w, t, x, y)
+			}
+
+			if !ok {
+				continue
+			}
+
+			src.X += frameWidth(data.Autotile) * SourceTileSize * tileFrame(data, x, y, step)
+
+			texture := data.Texture
+			if under := w.Tiles[y][x]; under != t {
+				if tex, ok := data.Transitions[under]; ok {
+					texture = tex
+				}
+			}
+
+			draws = append(draws, tileDraw{
+				texture: texture,
+				src:     src,
+				dest: sdl.Rect{
+					X: int32(x)*size - offsetX,
+					Y: int32(y)*size - offsetY,
+					W: size,
+					H: size,
+				},
+			})
+		}
+	}
+
+	return draws
+}
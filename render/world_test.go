@@ -0,0 +1,234 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+	"github.com/Zac-Garby/pieces-of-seven/loader"
+	"github.com/Zac-Garby/pieces-of-seven/world"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// The size of the screen drawn to by the benchmarks.
+const (
+	benchWidth  = 1200
+	benchHeight = 800
+)
+
+// benchWorld generates the world the benchmarks draw.
+func benchWorld(b *testing.B) *world.World {
+	w, err := world.Generate(1, world.GeneratorConfig{
+		Algorithm:  world.Noise,
+		Width:      256,
+		Height:     256,
+		LandRatio:  0.4,
+		NoiseScale: 32,
+		Octaves:    4,
+	})
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return w
+}
+
+// testRenderer makes a software renderer to draw to,
+// and a loader with a blank texture for every texture
+// the tiles use, so the tests don't need a window or
+// the assets.
+func testRenderer(tb testing.TB) (*sdl.Renderer, *loader.Loader, func()) {
+	surface, err := sdl.CreateRGBSurface(0, benchWidth, benchHeight, 32, 0xff000000, 0x00ff0000, 0x0000ff00, 0x000000ff)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	rend, err := sdl.CreateSoftwareRenderer(surface)
+	if err != nil {
+		surface.Free()
+		tb.Fatal(err)
+	}
+
+	ld := loader.New()
+
+	for _, tile := range world.Tiles {
+		data := tile.GetData()
+
+		names := []string{data.Texture}
+		for _, name := range data.Transitions {
+			names = append(names, name)
+		}
+
+		for _, name := range names {
+			if _, ok := ld.Textures[name]; ok {
+				continue
+			}
+
+			frames := int32(data.Frames)
+			if frames < 1 {
+				frames = 1
+			}
+
+			tex, err := rend.CreateTexture(
+				sdl.PIXELFORMAT_RGBA8888,
+				sdl.TEXTUREACCESS_STATIC,
+				frameWidth(data.Autotile)*SourceTileSize*frames,
+				8*SourceTileSize,
+			)
+
+			if err != nil {
+				tb.Fatal(err)
+			}
+
+			ld.Textures[name] = tex
+		}
+	}
+
+	free := func() {
+		for _, tex := range ld.Textures {
+			tex.Destroy()
+		}
+
+		rend.Destroy()
+		surface.Free()
+	}
+
+	return rend, &ld, free
+}
+
+func TestChunkSteps(t *testing.T) {
+	var (
+		rend, ld, free = testRenderer(t)
+		r              = NewWorldRenderer()
+		view           = &geom.Vector{}
+		rows           = make([]string, world.ChunkSize)
+	)
+
+	defer free()
+	defer r.Free()
+
+	// Three chunks side by side, with shallow water in
+	// the one on the right, which animates twice as fast
+	// as the water in the others.
+	for y := range rows {
+		rows[y] = strings.Repeat(".", world.ChunkSize*2) + strings.Repeat("s", world.ChunkSize)
+	}
+
+	shallow := world.Tile(world.ShallowWater).GetData()
+
+	defer func(frameTime float64) {
+		shallow.FrameTime = frameTime
+	}(shallow.FrameTime)
+
+	shallow.FrameTime = world.Tile(world.Water).GetData().FrameTime / 2
+
+	var (
+		w     = craftWorld(rows...)
+		width = world.ChunkSize * world.TileSize * 3
+		left  = world.ChunkCoord{X: 0, Y: 0}
+		right = world.ChunkCoord{X: 2, Y: 0}
+	)
+
+	r.Render(w, rend, ld, view, width, world.ChunkSize*world.TileSize)
+
+	if got := r.chunks[left].animated; len(got) != 1 || got[0] != world.Water {
+		t.Fatalf("expected only water to be animated on the left, got %v", got)
+	}
+
+	var (
+		leftSteps  = r.chunks[left].steps
+		rightSteps = r.chunks[right].steps
+	)
+
+	// After this, the shallow water has moved on a frame
+	// but the water hasn't, so only the chunk on the right
+	// needs baking again.
+	r.Update(shallow.FrameTime * 1.5)
+	r.Render(w, rend, ld, view, width, world.ChunkSize*world.TileSize)
+
+	if r.chunks[left].steps != leftSteps {
+		t.Error("the chunk on the left was baked again, but its animations hadn't moved on")
+	}
+
+	if r.chunks[right].steps == rightSteps {
+		t.Error("the chunk on the right wasn't baked again after its animation moved on")
+	}
+}
+
+// benchmarkRender draws the middle of the benchmark world
+// b.N times. If dt isn't zero, the animations are moved
+// on by dt seconds before each frame.
+func benchmarkRender(b *testing.B, drawTiles bool, dt float64) {
+	var (
+		w              = benchWorld(b)
+		rend, ld, free = testRenderer(b)
+		r              = NewWorldRenderer()
+		view           = &geom.Vector{
+			X: float64(w.Width*world.TileSize-benchWidth) / 2,
+			Y: float64(w.Height*world.TileSize-benchHeight) / 2,
+		}
+	)
+
+	defer free()
+	defer r.Free()
+
+	r.drawTiles = drawTiles
+
+	// The first frame bakes every visible chunk, which
+	// only happens once.
+	r.Render(w, rend, ld, view, benchWidth, benchHeight)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.Update(dt)
+		r.Render(w, rend, ld, view, benchWidth, benchHeight)
+	}
+}
+
+func BenchmarkRenderBaked(b *testing.B) {
+	benchmarkRender(b, false, 0)
+}
+
+// BenchmarkRenderBakedAnimated includes the chunks which
+// are baked again as their animations move on, at 60
+// frames a second.
+func BenchmarkRenderBakedAnimated(b *testing.B) {
+	benchmarkRender(b, false, 1.0/60)
+}
+
+func BenchmarkRenderTiles(b *testing.B) {
+	benchmarkRender(b, true, 0)
+}
+
+// BenchmarkTileDraws compares working out what to draw
+// for a screen of tiles, which per-tile drawing does
+// every frame, with doing it for one chunk, which baking
+// only does when the chunk changes.
+func BenchmarkTileDraws(b *testing.B) {
+	w := benchWorld(b)
+
+	b.Run("screen", func(b *testing.B) {
+		var (
+			x1 = benchWidth/world.TileSize + 3
+			y1 = benchHeight/world.TileSize + 3
+		)
+
+		for i := 0; i < b.N; i++ {
+			for _, tile := range world.Tiles {
+				tileDraws(w, tile, 0, 0, x1, y1, 0, 0, world.TileSize, 0)
+			}
+		}
+	})
+
+	b.Run("chunk", func(b *testing.B) {
+		x0, y0, x1, y1 := w.ChunkBounds(world.ChunkCoord{})
+
+		for i := 0; i < b.N; i++ {
+			for _, tile := range world.Tiles {
+				tileDraws(w, tile, x0, y0, x1, y1, 0, 0, SourceTileSize, 0)
+			}
+		}
+	})
+}
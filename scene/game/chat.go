@@ -6,33 +6,24 @@ import (
 
 	"github.com/Zac-Garby/pieces-of-seven/geom"
 	"github.com/Zac-Garby/pieces-of-seven/loader"
+	"github.com/Zac-Garby/pieces-of-seven/message"
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/veandco/go-sdl2/ttf"
 )
 
-type MessageType int
-
-const (
-	NoneMessage   MessageType = 0
-	GlobalMessage             = 1 << (iota - 1)
-	PrivateMessage
-	ServerMessage
-	DebugMessage
-)
-
 // By default, players see global, private, and server messages
-const DefaultMessageMask = GlobalMessage | PrivateMessage | ServerMessage
+const DefaultMessageMask = message.GlobalChat | message.PrivateChat | message.ServerChat
 
 // A Message contains the sender, text content,
 // and time sent, of a chat message.
 type Message struct {
 	Sender  string
 	Content string
-	Type    MessageType
+	Type    message.ChatType
 	Time    time.Time
 }
 
-func (m Message) IsVisible(mask MessageType) bool {
+func (m Message) IsVisible(mask message.ChatType) bool {
 	return m.Type&mask > 0
 }
 
@@ -41,7 +32,7 @@ func (m Message) IsVisible(mask MessageType) bool {
 type ChatLog struct {
 	Messages []*Message
 	Input    string
-	Mask     MessageType
+	Mask     message.ChatType
 }
 
 // NewChatLog creates a new ChatLog
@@ -1,27 +1,43 @@
 package game
 
 import (
-	"bufio"
 	"errors"
-	"net"
-
 	"fmt"
+	"io"
+	"net"
+	"sync"
 
 	"github.com/Zac-Garby/pieces-of-seven/entity"
 	"github.com/Zac-Garby/pieces-of-seven/message"
+	"github.com/Zac-Garby/pieces-of-seven/world"
+	"github.com/satori/go.uuid"
 )
 
-// EOT is the end of transmission character
-const EOT byte = 4
+// MaxFrameSize is the largest message, in bytes, which
+// the client accepts from the server.
+const MaxFrameSize = 64 << 20
 
 var ErrNoConnection = errors.New("no connection established")
 
+// InboxSize is the amount of received messages which can
+// be waiting to be handled by the game.
+const InboxSize = 256
+
 type Client struct {
 	Address string
 	Name    string
 	Game    *Game
 
-	conn net.Conn
+	// The optional features which both the client
+	// and the server support.
+	Capabilities []string
+
+	// conn and codec are set by Listen, but used by
+	// Send from the game's goroutine, so mu guards them.
+	mu    sync.Mutex
+	conn  net.Conn
+	codec message.Codec
+	inbox chan interface{}
 }
 
 func NewClient(addr string, game *Game, name string) *Client {
@@ -29,65 +45,135 @@ func NewClient(addr string, game *Game, name string) *Client {
 		Address: addr,
 		Game:    game,
 		Name:    name,
+		codec:   message.JSON,
+		inbox:   make(chan interface{}, InboxSize),
 	}
 
 	return c
 }
 
+// A disconnected message is put in the inbox when the
+// connection to the server can't be made, or is lost.
+type disconnected struct {
+	Reason string
+}
+
 func (c *Client) Listen() {
 	conn, err := net.Dial("tcp", c.Address)
 	if err != nil {
-		c.Game.shouldQuit = true
+		c.inbox <- &disconnected{
+			Reason: fmt.Sprintf("couldn't connect to %s", c.Address),
+		}
+
 		return
 	}
 
+	c.mu.Lock()
 	c.conn = conn
+	c.mu.Unlock()
 
 	c.SendClientInfo()
 
-	reader := bufio.NewReader(conn)
+	reader := message.NewFrameReader(conn, MaxFrameSize)
 
 	for {
-		reply, err := reader.ReadBytes(EOT)
+		reply, err := reader.ReadFrame()
 
 		// An error here will most likely be because
 		// the connection to the server was dropped.
 		if err != nil {
 			c.LeaveGame()
+
+			if err == io.EOF {
+				c.lost("lost connection to the server")
+			} else {
+				c.lost(err.Error())
+			}
+
 			break
 		}
 
-		reply = reply[:len(reply)-1]
+		c.mu.Lock()
+		codec := c.codec
+		c.mu.Unlock()
 
-		msg, err := message.Deserialize(reply)
+		msg, err := message.DeserializeWith(codec, reply)
 		if err != nil {
 			fmt.Println(err)
+			c.lost(err.Error())
 			break
 		}
 
-		c.handleMessage(msg)
+		if !message.Allowed(msg, message.ServerToClient) {
+			fmt.Printf("the server sent a %T, which only clients can send\n", msg)
+			continue
+		}
+
+		// The codec has to be switched here, rather than
+		// when the message is handled, since the very
+		// next message uses it.
+		if accepted, ok := msg.(*message.Accepted); ok {
+			if codec, ok := message.GetCodec(accepted.Codec); ok {
+				c.mu.Lock()
+				c.codec = codec
+				c.mu.Unlock()
+			}
+		}
+
+		// Messages are handled on the game's goroutine,
+		// in Update, so they don't race with the game.
+		c.inbox <- msg
+	}
+}
+
+// lost tells the game that the connection has ended. If
+// the inbox is full, or nothing is reading it because the
+// game has already been left, the reason is dropped.
+func (c *Client) lost(reason string) {
+	select {
+	case c.inbox <- &disconnected{Reason: reason}:
+	default:
+	}
+}
+
+// HandleMessages handles every message which has been
+// received since it was last called.
+func (c *Client) HandleMessages() {
+	for {
+		select {
+		case msg := <-c.inbox:
+			c.handleMessage(msg)
+		default:
+			return
+		}
 	}
 }
 
 func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return ErrNoConnection
+	}
+
 	return c.conn.Close()
 }
 
 func (c *Client) Send(msg interface{}) error {
-	b, err := message.Serialize(msg)
-	if err != nil {
-		return err
-	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	b = append(b, EOT)
-
-	if c.conn != nil {
-		c.conn.Write(b)
-	} else {
+	if c.conn == nil {
 		return ErrNoConnection
 	}
 
-	return nil
+	b, err := message.SerializeWith(c.codec, msg)
+	if err != nil {
+		return err
+	}
+
+	return message.WriteFrame(c.conn, b)
 }
 
 func (c *Client) LeaveGame() error {
@@ -96,11 +182,30 @@ func (c *Client) LeaveGame() error {
 
 func (c *Client) handleMessage(msg interface{}) {
 	switch m := msg.(type) {
+	case *disconnected:
+		c.Game.quit(m.Reason)
+
+	case *message.Rejected:
+		c.Game.quit("the server rejected you: " + m.Reason)
+
+	case *message.Accepted:
+		c.Capabilities = m.Capabilities
+
 	case *message.GameInfo:
-		// Initialise the game's world with
-		// the provided tiles.
-		c.Game.World.Tiles = m.Tiles
-		c.Game.World.MakeGraph()
+		if err := world.CheckSize(m.Width, m.Height); err != nil {
+			c.Game.quit(err.Error())
+			return
+		}
+
+		if _, ok := m.Players[m.ID]; !ok {
+			c.Game.quit("the server didn't send your ship")
+			return
+		}
+
+		// The world's tiles are streamed in afterwards,
+		// so start off with an empty one.
+		c.Game.World = world.NewUnloaded(m.Width, m.Height)
+		c.Game.World.Seed = m.Seed
 
 		// Add the existing players to the game.
 		for id, apl := range m.Players {
@@ -109,10 +214,11 @@ func (c *Client) handleMessage(msg interface{}) {
 				apl.Position.Y,
 			)
 
-			ship.Move(apl.Destination, c.Game.World)
-
 			if id == m.ID {
 				c.Game.Player = ship
+				c.sync(ship, apl)
+			} else {
+				c.addRemote(id, ship, apl)
 			}
 
 			c.Game.Entities = append(c.Game.Entities, ship)
@@ -121,15 +227,20 @@ func (c *Client) handleMessage(msg interface{}) {
 
 		c.Game.Player.Name = c.Name
 
+	case *message.WorldChunk:
+		if err := c.Game.World.LoadChunk(m.Chunk, m.Data); err != nil {
+			fmt.Println(err)
+		}
+
 	case *message.NewPlayer:
-		ship := entity.NewShip(
-			m.Player.Position.X,
-			m.Player.Position.Y,
-		)
+		if _, exists := c.Game.Players[m.ID]; !exists {
+			ship := entity.NewShip(
+				m.Player.Position.X,
+				m.Player.Position.Y,
+			)
 
-		ship.Move(m.Player.Destination, c.Game.World)
+			c.addRemote(m.ID, ship, m.Player)
 
-		if _, exists := c.Game.Players[m.ID]; !exists {
 			c.Game.Players[m.ID] = ship
 			c.Game.Entities = append(c.Game.Entities, ship)
 		}
@@ -146,23 +257,56 @@ func (c *Client) handleMessage(msg interface{}) {
 		}
 
 		delete(c.Game.Players, m.ID)
+		delete(c.Game.remotes, m.ID)
+
+	case *message.Snapshot:
+		for id, apl := range m.Players {
+			if remote, ok := c.Game.remotes[id]; ok {
+				remote.push(c.Game.clock, apl)
+			} else if ship, ok := c.Game.Players[id]; ok && ship == c.Game.Player {
+				c.Game.reconcile(apl)
+			}
+		}
 
-	case *message.PlayerMoved:
-		c.Game.Players[m.ID].Move(m.Position, c.Game.World)
+	case *message.Correction:
+		c.Game.reconcile(m.Player)
 
 	case *message.ChatMessage:
 		c.Game.ChatLog.Messages = append(c.Game.ChatLog.Messages, &Message{
 			Content: m.Content,
 			Sender:  m.Sender,
 			Time:    m.Time,
-			Type:    GlobalMessage,
+			Type:    message.GlobalChat,
 		})
 	}
 }
 
+// sync sets a ship's state to the server's version
+// of it, then sets it sailing to its destination.
+func (c *Client) sync(ship *entity.Ship, apl message.AbstractPlayer) {
+	ship.Pos = apl.Position
+	ship.ApparentPos = apl.ApparentPos
+	ship.Path = nil
+
+	ship.Move(apl.Destination, c.Game.World)
+}
+
+// addRemote starts interpolating another player's ship.
+func (c *Client) addRemote(id uuid.UUID, ship *entity.Ship, apl message.AbstractPlayer) {
+	ship.ApparentPos = apl.ApparentPos
+
+	remote := newInterpolator(ship)
+	remote.push(c.Game.clock, apl)
+
+	c.Game.remotes[id] = remote
+}
+
 func (c *Client) SendClientInfo() error {
 	info := &message.ClientInfo{
-		Name: c.Name,
+		Name:         c.Name,
+		Version:      message.ProtocolVersion,
+		Capabilities: message.Capabilities,
+		Codecs:       message.CodecNames(),
 	}
 
 	return c.Send(info)
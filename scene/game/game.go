@@ -1,12 +1,15 @@
 package game
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Zac-Garby/pieces-of-seven/entity"
 	"github.com/Zac-Garby/pieces-of-seven/geom"
 	"github.com/Zac-Garby/pieces-of-seven/loader"
 	"github.com/Zac-Garby/pieces-of-seven/message"
+	"github.com/Zac-Garby/pieces-of-seven/render"
 	"github.com/Zac-Garby/pieces-of-seven/world"
 	"github.com/satori/go.uuid"
 	"github.com/veandco/go-sdl2/sdl"
@@ -37,24 +40,32 @@ type Game struct {
 	ChatLog    *ChatLog
 
 	ld           *loader.Loader
+	renderer     *render.WorldRenderer
+	clock        float64
+	seq          uint32
+	pending      []pendingMove
+	remotes      map[uuid.UUID]*interpolator
 	nextTick     float64
 	nextUpdate   float64
 	shouldQuit   bool
+	quitReason   string
 	shouldSetCam bool
 }
 
 // New creates a new Game instance.
 func New(ld *loader.Loader, addr, name string) *Game {
 	game := &Game{
-		World:        &world.World{},
+		World:        world.NewUnloaded(world.DefaultWidth, world.DefaultHeight),
 		ViewOffset:   &geom.Vector{X: 0, Y: 0},
 		ChatLog:      NewChatLog(),
 		nextTick:     1.0 / TickRate,
 		nextUpdate:   1.0 / ServerUpdateRate,
 		ld:           ld,
+		renderer:     render.NewWorldRenderer(),
 		shouldQuit:   false,
 		shouldSetCam: true,
 		Players:      make(map[uuid.UUID]*entity.Ship),
+		remotes:      make(map[uuid.UUID]*interpolator),
 	}
 
 	game.Client = NewClient(addr, game, name)
@@ -76,6 +87,8 @@ func (g *Game) Exit() {
 	}
 
 	sdl.StopTextInput()
+
+	g.renderer.Free()
 }
 
 // Update updates the game by 'dt' seconds. The returned
@@ -84,12 +97,18 @@ func (g *Game) Exit() {
 func (g *Game) Update(dt float64) string {
 	if g.shouldQuit {
 		g.Client.LeaveGame()
-		return "mainmenu"
+
+		// The reason is shown in the joingame scene
+		return "joingame\n" + strings.Replace(g.quitReason, "\n", " ", -1)
 	}
 
+	g.clock += dt
+	g.renderer.Update(dt)
 	g.nextTick -= dt
 	g.nextUpdate -= dt
 
+	g.Client.HandleMessages()
+
 	if g.nextTick <= 0 {
 		g.nextTick = 1.0 / TickRate
 		g.tick()
@@ -100,6 +119,10 @@ func (g *Game) Update(dt float64) string {
 		g.serverUpdate()
 	}
 
+	for _, remote := range g.remotes {
+		remote.update(g.clock)
+	}
+
 	for _, e := range g.Entities {
 		e.Update(dt)
 	}
@@ -110,10 +133,10 @@ func (g *Game) Update(dt float64) string {
 // Render renders a game (i.e. the objects inside it)
 // onto an SDL renderer.
 func (g *Game) Render(rend *sdl.Renderer, width, height int) {
-	g.World.Render(rend, g.ld, g.ViewOffset, width-ChatLogWidth, height)
+	g.renderer.Render(g.World, rend, g.ld, g.ViewOffset, width-ChatLogWidth, height)
 
 	for _, e := range g.Entities {
-		e.Render(g.ViewOffset, g.ld, rend)
+		render.Entity(e, g.ViewOffset, g.ld, rend)
 	}
 
 	if g.Player != nil {
@@ -141,18 +164,42 @@ func (g *Game) Render(rend *sdl.Renderer, width, height int) {
 			g.ViewOffset.Y = 0
 		}
 
-		if g.ViewOffset.X+float64(width) > (world.Width*world.TileSize)+ChatLogWidth {
-			g.ViewOffset.X = float64(world.Width*world.TileSize - width + ChatLogWidth)
+		if g.ViewOffset.X+float64(width) > float64(g.World.Width*world.TileSize+ChatLogWidth) {
+			g.ViewOffset.X = float64(g.World.Width*world.TileSize - width + ChatLogWidth)
 		}
 
-		if g.ViewOffset.Y+float64(height) > world.Height*world.TileSize {
-			g.ViewOffset.Y = float64(world.Height*world.TileSize - height)
+		if g.ViewOffset.Y+float64(height) > float64(g.World.Height*world.TileSize) {
+			g.ViewOffset.Y = float64(g.World.Height*world.TileSize - height)
 		}
 	}
 
+	g.renderLoading(rend)
+
 	g.ChatLog.Render(rend, g.ld, width-ChatLogWidth, 0, ChatLogWidth, height)
 }
 
+// renderLoading shows how much of the world has been
+// received, while it's still being streamed in.
+func (g *Game) renderLoading(rend *sdl.Renderer) {
+	loaded, total := g.World.LoadProgress()
+	if loaded == total {
+		return
+	}
+
+	text := fmt.Sprintf("Loading world... %d%%", loaded*100/total)
+	surface, tex := renderText(text, g.ld.Fonts["body-sm"], sdl.Color{R: 255, G: 255, B: 255, A: 255}, rend, -1)
+
+	rend.Copy(tex, &surface.ClipRect, &sdl.Rect{
+		X: 10,
+		Y: 10,
+		W: surface.ClipRect.W,
+		H: surface.ClipRect.H,
+	})
+
+	surface.Free()
+	tex.Destroy()
+}
+
 // HandleEvent handles a window event, such as a mouse
 // click or a key release.
 func (g *Game) HandleEvent(event sdl.Event) string {
@@ -173,11 +220,9 @@ func (g *Game) HandleEvent(event sdl.Event) string {
 				Y: uint(ty),
 			}
 
-			// Move the player locally
-			g.Player.Move(coord, g.World)
-
-			// Tell the server the player's moved
-			g.Client.Send(&message.Moved{Position: coord})
+			// Move the player locally, and tell the
+			// server the player's moved
+			g.moveTo(coord)
 		}
 
 	case *sdl.KeyUpEvent:
@@ -201,7 +246,7 @@ func (g *Game) HandleEvent(event sdl.Event) string {
 				Content: g.ChatLog.Input,
 				Sender:  g.Player.Name,
 				Time:    time.Now(),
-				Type:    GlobalMessage,
+				Type:    message.GlobalChat,
 			})
 
 			g.ChatLog.Input = ""
@@ -249,8 +294,6 @@ func (g *Game) PositionToTile(x, y int32) (int32, int32) {
 }
 
 func (g *Game) tick() {
-	g.World.Tick()
-
 	for _, e := range g.Entities {
 		e.Step()
 	}
@@ -264,6 +307,18 @@ func (g *Game) serverUpdate() {
 	}
 }
 
+// quit leaves the game at the next update, for the given
+// reason. Only the first reason is kept, since it's the
+// one which caused the others.
+func (g *Game) quit(reason string) {
+	if g.shouldQuit {
+		return
+	}
+
+	g.shouldQuit = true
+	g.quitReason = reason
+}
+
 func lerp(a, b, t float64) float64 {
 	return (1-t)*a + t*b
 }
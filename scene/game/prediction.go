@@ -0,0 +1,146 @@
+package game
+
+import (
+	"github.com/Zac-Garby/pieces-of-seven/entity"
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+	"github.com/Zac-Garby/pieces-of-seven/message"
+)
+
+// InterpolationDelay is how far in the past, in seconds,
+// other players' ships are shown. It should be a bit
+// more than the time between two snapshots, so there's
+// almost always a pair of snapshots to blend between.
+const InterpolationDelay = 0.2
+
+// A pendingMove is a move which the player has made
+// locally, but which the server hasn't acknowledged yet.
+type pendingMove struct {
+	Seq    uint32
+	Target geom.Coord
+}
+
+// moveTo moves the player's ship straight away, without
+// waiting for the server, and tells the server about it.
+func (g *Game) moveTo(coord geom.Coord) {
+	if g.Player == nil {
+		return
+	}
+
+	g.seq++
+
+	g.Player.Move(coord, g.World)
+	g.pending = append(g.pending, pendingMove{Seq: g.seq, Target: coord})
+
+	g.Client.Send(&message.Moved{
+		Position: coord,
+		Seq:      g.seq,
+	})
+}
+
+// reconcile is called when the server sends the
+// authoritative state of the player's ship. The moves
+// the server hasn't seen yet are replayed on top of it
+// and, if the result disagrees with the local prediction,
+// the prediction is replaced.
+//
+// Replaying searches for a path for every move, so it's
+// skipped when the server has seen every move and agrees
+// with the prediction, which is almost always.
+func (g *Game) reconcile(apl message.AbstractPlayer) {
+	if g.Player == nil {
+		return
+	}
+
+	// Forget about the moves the server has processed
+	i := 0
+	for i < len(g.pending) && g.pending[i].Seq <= apl.Seq {
+		i++
+	}
+
+	g.pending = g.pending[i:]
+
+	if len(g.pending) == 0 && apl.Destination == g.Player.Destination() &&
+		g.Player.Distance(apl.ApparentPos) <= entity.SyncTolerance {
+		return
+	}
+
+	replayed := entity.NewShip(apl.Position.X, apl.Position.Y)
+	replayed.ApparentPos = apl.ApparentPos
+	replayed.Move(apl.Destination, g.World)
+
+	for _, move := range g.pending {
+		replayed.Move(move.Target, g.World)
+	}
+
+	if replayed.Destination() == g.Player.Destination() &&
+		g.Player.Distance(replayed.ApparentPos) <= entity.SyncTolerance {
+		return
+	}
+
+	g.Player.Pos = replayed.Pos
+	g.Player.ApparentPos = replayed.ApparentPos
+	g.Player.Path = replayed.Path
+}
+
+// A shipState is the position of a ship at a
+// certain point in time.
+type shipState struct {
+	Time float64
+	Pos  geom.Coord
+	Vec  geom.Vector
+}
+
+// An interpolator moves another player's ship smoothly
+// between the states received in snapshots, rather than
+// simulating its movement locally.
+type interpolator struct {
+	ship   *entity.Ship
+	states []shipState
+}
+
+func newInterpolator(ship *entity.Ship) *interpolator {
+	return &interpolator{ship: ship}
+}
+
+// push adds a state received at time t.
+func (i *interpolator) push(t float64, apl message.AbstractPlayer) {
+	i.states = append(i.states, shipState{
+		Time: t,
+		Pos:  apl.Position,
+		Vec:  apl.ApparentPos,
+	})
+}
+
+// update places the ship where it was InterpolationDelay
+// seconds before now.
+func (i *interpolator) update(now float64) {
+	if len(i.states) == 0 {
+		return
+	}
+
+	t := now - InterpolationDelay
+
+	// Drop the states which are no longer needed, keeping
+	// the latest one before t.
+	for len(i.states) > 1 && i.states[1].Time <= t {
+		i.states = i.states[1:]
+	}
+
+	from := i.states[0]
+
+	if len(i.states) == 1 || t <= from.Time {
+		i.ship.Pos = from.Pos
+		i.ship.SetApparentPos(from.Vec)
+
+		return
+	}
+
+	to := i.states[1]
+	frac := (t - from.Time) / (to.Time - from.Time)
+
+	i.ship.Pos = from.Pos
+	i.ship.SetApparentPos(geom.Vector{
+		X: lerp(from.Vec.X, to.Vec.X, frac),
+		Y: lerp(from.Vec.Y, to.Vec.Y, frac),
+	})
+}
@@ -15,8 +15,10 @@ type JoinGame struct {
 	inter *ui.Interface
 }
 
-// New creates a new JoinGame scene.
-func New(ld *loader.Loader) *JoinGame {
+// New creates a new JoinGame scene. If the player was
+// sent back here because joining a game failed, reason
+// says why, and is shown above the prompts.
+func New(ld *loader.Loader, reason string) *JoinGame {
 	join := &JoinGame{
 		ld: ld,
 		inter: &ui.Interface{
@@ -24,6 +26,17 @@ func New(ld *loader.Loader) *JoinGame {
 		},
 	}
 
+	if len(reason) > 0 {
+		join.inter.Add("error", ui.NewText(
+			reason,
+			255, 90, 90,
+			ld.Fonts["body-sm"],
+			ui.LeftAlign,
+		))
+
+		join.inter.Add("space0", ui.NewText(" ", 0, 0, 0, ld.Fonts["body"], ui.LeftAlign))
+	}
+
 	join.inter.Add("name-prompt", ui.NewText(
 		"What's your name?",
 		255, 255, 255,
@@ -19,7 +19,12 @@ func makeScene(name string, ld *loader.Loader) scene.Scene {
 	case "mainmenu":
 		return mainmenu.New(ld)
 	case "joingame":
-		return joingame.New(ld)
+		reason := ""
+		if len(split) > 1 {
+			reason = split[1]
+		}
+
+		return joingame.New(ld, reason)
 	default:
 		panic("scene not found: " + name)
 	}
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"time"
+
+	"github.com/Zac-Garby/pieces-of-seven/world"
+)
+
+// A Config contains the settings a Server is
+// created with.
+type Config struct {
+	// Address is the address to listen on, such
+	// as ":12358".
+	Address string
+
+	// Seed is the seed the world is generated from.
+	// The same seed always generates the same world.
+	Seed int64
+
+	// Generator contains the size of the world and
+	// the parameters it's generated with.
+	Generator world.GeneratorConfig
+
+	// SpawnZone, if it's set, is the area of the world
+	// where players start.
+	SpawnZone *world.Zone
+
+	// WorldPath, if it's set, is where the world is saved.
+	// If there's already a save there when the server
+	// starts, it's loaded instead of generating a new
+	// world.
+	WorldPath string
+
+	// SaveInterval is how often the world is saved, or
+	// DefaultSaveInterval if it's zero.
+	SaveInterval time.Duration
+
+	// MapPath, if it's set, is a hand-made map which the
+	// world is imported from instead of being generated.
+	// It can be a PNG map image or a Tiled map. A save at
+	// WorldPath still takes priority.
+	MapPath string
+
+	// OverviewPath, if it's set, is where a PNG overview
+	// of the world is written when the server starts.
+	OverviewPath string
+
+	// Palette maps tiles to their colours in map images,
+	// or is world.DefaultPalette() if it's nil.
+	Palette world.Palette
+}
@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"net"
+
+	"github.com/Zac-Garby/pieces-of-seven/message"
+	"github.com/satori/go.uuid"
+)
+
+// OutboxSize is the amount of outgoing messages which
+// can be queued for a single client. If a client falls
+// this far behind, it's disconnected.
+const OutboxSize = 256
+
+// A connection is a single client's connection to the
+// server. Outgoing messages are queued in the outbox and
+// written by the connection's own goroutine, so a slow
+// client can never block the server's event loop.
+//
+// A connection can also stream a long list of messages,
+// such as the world's chunks, which are only written
+// when there's nothing in the outbox. That way, the game
+// carries on while they're sent in the background.
+type connection struct {
+	id      uuid.UUID
+	conn    net.Conn
+	codec   message.Codec
+	outbox  chan []byte
+	streams chan [][]byte
+}
+
+func newConnection(id uuid.UUID, conn net.Conn, codec message.Codec) *connection {
+	c := &connection{
+		id:      id,
+		conn:    conn,
+		codec:   codec,
+		outbox:  make(chan []byte, OutboxSize),
+		streams: make(chan [][]byte, 1),
+	}
+
+	go c.writeLoop()
+
+	return c
+}
+
+// writeLoop writes queued messages to the client until
+// the outbox is closed, then closes the connection.
+func (c *connection) writeLoop() {
+	var stream [][]byte
+
+	for {
+		var (
+			b  []byte
+			ok bool
+		)
+
+		select {
+		case b, ok = <-c.outbox:
+		default:
+			if len(stream) > 0 {
+				b, ok = stream[0], true
+				stream = stream[1:]
+
+				break
+			}
+
+			select {
+			case b, ok = <-c.outbox:
+			case frames := <-c.streams:
+				stream = append(stream, frames...)
+				continue
+			}
+		}
+
+		if !ok {
+			break
+		}
+
+		if _, err := c.conn.Write(b); err != nil {
+			break
+		}
+	}
+
+	c.conn.Close()
+
+	// Drain anything left so the event loop is never
+	// blocked on a dead connection.
+	for range c.outbox {
+	}
+}
+
+// queue adds a message to the outbox, returning false
+// if the outbox is full.
+func (c *connection) queue(b []byte) bool {
+	select {
+	case c.outbox <- b:
+		return true
+	default:
+		return false
+	}
+}
+
+// stream adds some messages to be written whenever the
+// outbox is empty, returning false if a stream is already
+// waiting to be picked up.
+func (c *connection) stream(frames [][]byte) bool {
+	select {
+	case c.streams <- frames:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops the write loop once the queued messages
+// have been written. It must only be called once, from
+// the event loop.
+func (c *connection) close() {
+	close(c.outbox)
+}
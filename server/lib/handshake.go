@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Zac-Garby/pieces-of-seven/message"
+)
+
+// HandshakeTimeout is how long a client has to send its
+// ClientInfo after connecting.
+const HandshakeTimeout = 10 * time.Second
+
+// handshake reads a client's ClientInfo and checks that
+// the client speaks the same protocol, choosing a codec
+// for the rest of the connection. If the client can't
+// join, it's sent the reason it was rejected, and a nil
+// ClientInfo is returned.
+func handshake(conn net.Conn, reader *message.FrameReader) (*message.ClientInfo, message.Codec) {
+	conn.SetReadDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	bytes, err := reader.ReadFrame()
+	if err != nil {
+		if err != io.EOF {
+			reject(conn, fmt.Sprintf("couldn't read handshake: %s", err))
+		}
+
+		return nil, nil
+	}
+
+	msg, err := message.Deserialize(bytes)
+	if err != nil {
+		reject(conn, fmt.Sprintf("couldn't read handshake: %s", err))
+		return nil, nil
+	}
+
+	info, ok := msg.(*message.ClientInfo)
+	if !ok {
+		reject(conn, "expected a ClientInfo to begin the handshake")
+		return nil, nil
+	}
+
+	if info.Version != message.ProtocolVersion {
+		reject(conn, fmt.Sprintf(
+			"incompatible versions: the server speaks protocol v%d, but you speak v%d",
+			message.ProtocolVersion, info.Version,
+		))
+
+		return nil, nil
+	}
+
+	if strings.TrimSpace(info.Name) == "" {
+		reject(conn, "no name: you need a name to join the game")
+		return nil, nil
+	}
+
+	codec, ok := message.ChooseCodec(info.Codecs)
+	if !ok {
+		reject(conn, fmt.Sprintf(
+			"no common codec: the server supports %s",
+			strings.Join(message.CodecNames(), ", "),
+		))
+
+		return nil, nil
+	}
+
+	return info, codec
+}
+
+// reject sends a Rejected message straight to a client
+// which hasn't joined the game.
+func reject(conn net.Conn, reason string) {
+	b, err := message.Serialize(&message.Rejected{Reason: reason})
+	if err != nil {
+		return
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(HandshakeTimeout))
+	message.WriteFrame(conn, b)
+}
@@ -0,0 +1,175 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+	"github.com/Zac-Garby/pieces-of-seven/world"
+)
+
+// DefaultSaveInterval is how often the server saves the
+// world, if the config doesn't say.
+const DefaultSaveInterval = time.Minute
+
+// positionsKey is the key in the world's metadata where
+// the players' positions are saved.
+const positionsKey = "positions"
+
+// loadWorld loads the world saved at the config's world
+// path. If there isn't one, or there's no path, it's
+// imported from the config's map, or else a new world
+// is generated.
+func loadWorld(cfg Config) (w *world.World, loaded bool, err error) {
+	if cfg.WorldPath != "" {
+		w, err := world.LoadFile(cfg.WorldPath)
+		if err == nil {
+			return w, true, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, false, fmt.Errorf("loading %s: %s", cfg.WorldPath, err)
+		}
+	}
+
+	if cfg.MapPath != "" {
+		w, err := world.ImportFile(cfg.MapPath, cfg.palette())
+		return w, false, err
+	}
+
+	w, err = world.Generate(cfg.Seed, cfg.Generator)
+
+	return w, false, err
+}
+
+// palette returns the config's palette, or the default
+// one if it doesn't have one.
+func (cfg Config) palette() world.Palette {
+	if cfg.Palette == nil {
+		return world.DefaultPalette()
+	}
+
+	return cfg.Palette
+}
+
+// exportOverview writes a PNG overview of the world to
+// the config's overview path.
+func (s *Server) exportOverview() error {
+	f, err := os.Create(s.Config.OverviewPath)
+	if err != nil {
+		return err
+	}
+
+	if err := s.World.ExportImage(f, s.Config.palette(), 1); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// loadPositions reads the players' positions from the
+// world's metadata, so players who come back start
+// where they left.
+func (s *Server) loadPositions() {
+	s.positions = make(map[string]geom.Coord)
+
+	data, ok := s.World.Metadata[positionsKey]
+	if !ok {
+		return
+	}
+
+	if err := json.Unmarshal([]byte(data), &s.positions); err != nil {
+		fmt.Println("in loadPositions:", err)
+	}
+}
+
+// savedPosition returns where a player was when they
+// last left, if it's somewhere they can still start.
+func (s *Server) savedPosition(name string) (geom.Coord, bool) {
+	pos, ok := s.positions[name]
+	if !ok || !s.World.InBounds(int(pos.X), int(pos.Y)) {
+		return geom.Coord{}, false
+	}
+
+	if !s.World.Tiles[pos.Y][pos.X].GetData().Passable || s.occupied(pos) {
+		return geom.Coord{}, false
+	}
+
+	return pos, true
+}
+
+// snapshot encodes the world, along with the players'
+// positions, in the world save format.
+func (s *Server) snapshot() ([]byte, error) {
+	for _, ship := range s.Players {
+		s.positions[ship.Name] = ship.Pos
+	}
+
+	positions, err := json.Marshal(s.positions)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.World.Metadata == nil {
+		s.World.Metadata = make(map[string]string)
+	}
+
+	s.World.Metadata[positionsKey] = string(positions)
+
+	var buf bytes.Buffer
+
+	if err := s.World.Save(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// save saves a snapshot to the config's world path. The
+// snapshot is taken straight away, but it's written in
+// the background unless wait is true, so the event loop
+// doesn't have to wait for the disk.
+func (s *Server) save(wait bool) {
+	data, err := s.snapshot()
+	if err != nil {
+		fmt.Println("in save:", err)
+		return
+	}
+
+	s.saves++
+	seq := s.saves
+
+	if wait {
+		s.write(seq, data)
+	} else {
+		go s.write(seq, data)
+	}
+}
+
+// write writes a snapshot to the world path, unless a
+// newer one has already been written.
+func (s *Server) write(seq uint64, data []byte) {
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+
+	if seq <= s.written {
+		return
+	}
+
+	err := world.WriteFile(s.Config.WorldPath, func(f io.Writer) error {
+		_, err := f.Write(data)
+		return err
+	})
+
+	if err != nil {
+		fmt.Println("in save:", err)
+		return
+	}
+
+	s.written = seq
+}
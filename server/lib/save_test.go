@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+	"github.com/Zac-Garby/pieces-of-seven/message"
+	"github.com/Zac-Garby/pieces-of-seven/world"
+)
+
+// visit joins a server and leaves again straight away,
+// returning where the player's ship started.
+func visit(t *testing.T, addr, name string) geom.Coord {
+	c, err := join(addr, name, message.JSON.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer c.conn.Close()
+
+	if err := c.send(&message.Disconnect{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server closes the connection once the player
+	// has left.
+	for {
+		if _, err := c.read(); err != nil {
+			return c.pos
+		}
+	}
+}
+
+func TestSavePositions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	cfg := testConfig()
+	cfg.WorldPath = filepath.Join(dir, "test.world")
+
+	s, ln := newTestServer(t, cfg)
+	first := visit(t, ln.Addr().String(), "anne")
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := world.LoadFile(cfg.WorldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var positions map[string]geom.Coord
+
+	if err := json.Unmarshal([]byte(w.Metadata[positionsKey]), &positions); err != nil {
+		t.Fatal(err)
+	}
+
+	if pos, ok := positions["anne"]; !ok || pos != first {
+		t.Errorf("expected anne to be saved at %v, got %v", first, positions)
+	}
+
+	// The saved world is loaded, rather than a new one
+	// being generated from the new seed.
+	cfg.Seed = 2
+
+	s, ln = newTestServer(t, cfg)
+	defer s.Close()
+
+	if !s.Loaded || s.World.Seed != 1 {
+		t.Fatalf("expected the saved world to be loaded, got seed %d", s.World.Seed)
+	}
+
+	if again := visit(t, ln.Addr().String(), "anne"); again != first {
+		t.Errorf("expected anne to start at %v again, got %v", first, again)
+	}
+}
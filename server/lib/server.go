@@ -1,205 +1,600 @@
 package lib
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"net"
-
+	"sync"
 	"time"
 
 	"github.com/Zac-Garby/pieces-of-seven/entity"
+	"github.com/Zac-Garby/pieces-of-seven/geom"
 	"github.com/Zac-Garby/pieces-of-seven/message"
-	"github.com/Zac-Garby/pieces-of-seven/scene/game"
 	"github.com/Zac-Garby/pieces-of-seven/world"
 	"github.com/satori/go.uuid"
 )
 
-// EOT is the end of transmission character
-const EOT byte = 4
+// ErrServerClosed is returned by Serve once the
+// server has been closed.
+var ErrServerClosed = errors.New("server closed")
 
+// A Server hosts a game. All of its state is owned by
+// a single event loop: connections only ever talk to
+// it by sending events, so joining, leaving, moving
+// and chatting can never race with each other.
 type Server struct {
 	World   *world.World
 	Players map[uuid.UUID]*entity.Ship
-	Address string
+	Config  Config
+
+	// MaxFrameSize is the largest message, in bytes, which
+	// a client is allowed to send. Clients which send
+	// larger ones are disconnected.
+	MaxFrameSize int
+
+	// Loaded is true if the world was loaded from a save,
+	// rather than being generated.
+	Loaded bool
+
+	acks        map[uuid.UUID]uint32
+	connections map[uuid.UUID]*connection
+	events      chan interface{}
+	quit        chan struct{}
+	quitOnce    sync.Once
+	done        chan struct{}
+	traffic     *traffic
+	ticks       uint64
+
+	// chunks caches each chunk's WorldChunk frame in each
+	// codec, so streaming the world only encodes the
+	// chunks which have changed since they were last sent.
+	chunks map[chunkKey]*encodedChunk
+
+	// positions holds where each player, by name, was
+	// when they left or the world was last saved.
+	positions map[string]geom.Coord
+	saves     uint64
+	written   uint64
+	saveMutex sync.Mutex
+}
+
+// These are the events which connections send to
+// the event loop.
+type (
+	joinEvent struct {
+		conn *connection
+		info *message.ClientInfo
+	}
 
-	closed      map[uuid.UUID]bool
-	connections map[uuid.UUID]net.Conn
+	leaveEvent struct {
+		id uuid.UUID
+	}
+
+	messageEvent struct {
+		id  uuid.UUID
+		msg interface{}
+	}
+)
+
+// A chunkKey is a chunk encoded with a codec.
+type chunkKey struct {
+	codec message.Codec
+	chunk world.ChunkCoord
+}
+
+// An encodedChunk is a chunk's WorldChunk frame, along
+// with the chunk's version when it was encoded.
+type encodedChunk struct {
+	version uint64
+	frame   []byte
 }
 
-func New(addr string) *Server {
+// New creates a new Server. Its world is loaded from the
+// config's world path if there's a save there, or else
+// generated from the config's seed and generator
+// settings.
+func New(cfg Config) (*Server, error) {
+	w, loaded, err := loadWorld(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.SpawnZone != nil {
+		w.SpawnZone = cfg.SpawnZone
+	}
+
+	// The path-finding hierarchy is built now, rather
+	// than when the first player moves.
+	w.Hierarchy.Update()
+
 	s := &Server{
-		World:       world.Generate(),
-		Address:     addr,
-		Players:     make(map[uuid.UUID]*entity.Ship),
-		connections: make(map[uuid.UUID]net.Conn),
-		closed:      make(map[uuid.UUID]bool),
+		World:        w,
+		Config:       cfg,
+		MaxFrameSize: message.DefaultMaxFrameSize,
+		Players:      make(map[uuid.UUID]*entity.Ship),
+		acks:         make(map[uuid.UUID]uint32),
+		connections:  make(map[uuid.UUID]*connection),
+		chunks:       make(map[chunkKey]*encodedChunk),
+		events:       make(chan interface{}),
+		quit:         make(chan struct{}),
+		done:         make(chan struct{}),
+		traffic:      newTraffic(),
+		Loaded:       loaded,
 	}
 
-	s.World.MakeGraph()
+	s.loadPositions()
+
+	if cfg.OverviewPath != "" {
+		if err := s.exportOverview(); err != nil {
+			return nil, fmt.Errorf("exporting overview: %s", err)
+		}
+	}
 
-	return s
+	go s.run()
+
+	return s, nil
 }
 
+// Listen listens on the server's address and serves
+// connections until an error occurs.
 func (s *Server) Listen() error {
-	ln, err := net.Listen("tcp", s.Address)
+	ln, err := net.Listen("tcp", s.Config.Address)
 	if err != nil {
 		return err
 	}
 
+	return s.Serve(ln)
+}
+
+// Serve accepts connections from the given listener
+// until the server is closed.
+func (s *Server) Serve(ln net.Listener) error {
+	go func() {
+		<-s.quit
+		ln.Close()
+	}()
+
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			fmt.Println(err)
+			select {
+			case <-s.quit:
+				return ErrServerClosed
+			default:
+			}
+
+			return err
 		}
 
 		go s.handleConnection(conn)
 	}
 }
 
+// Close stops the server, disconnecting every client.
+// It's safe to call more than once, and every call waits
+// until the server has stopped.
+func (s *Server) Close() error {
+	s.quitOnce.Do(func() {
+		close(s.quit)
+	})
+
+	<-s.done
+
+	return nil
+}
+
+// run is the event loop. It's the only goroutine which
+// touches the server's state, and it also runs the
+// simulation at TickRate.
+func (s *Server) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(time.Second / TickRate)
+	defer ticker.Stop()
+
+	// The world is only saved if there's somewhere to
+	// save it, and straight away so a new one isn't lost.
+	var saves <-chan time.Time
+
+	if s.Config.WorldPath != "" {
+		interval := s.Config.SaveInterval
+		if interval <= 0 {
+			interval = DefaultSaveInterval
+		}
+
+		saver := time.NewTicker(interval)
+		defer saver.Stop()
+
+		saves = saver.C
+
+		s.save(false)
+	}
+
+	for {
+		select {
+		case evt := <-s.events:
+			s.handleEvent(evt)
+
+		case <-ticker.C:
+			s.tick()
+
+		case <-saves:
+			s.save(false)
+
+		case <-s.quit:
+			for id := range s.connections {
+				s.removeConnection(id)
+			}
+
+			if s.Config.WorldPath != "" {
+				s.save(true)
+			}
+
+			return
+		}
+	}
+}
+
+// post sends an event to the event loop, returning false
+// if the server has been closed.
+func (s *Server) post(evt interface{}) bool {
+	select {
+	case s.events <- evt:
+		return true
+	case <-s.quit:
+		return false
+	}
+}
+
+func (s *Server) handleEvent(evt interface{}) {
+	switch e := evt.(type) {
+	case joinEvent:
+		s.handleJoin(e.conn, e.info)
+
+	case leaveEvent:
+		s.handleDisconnect(e.id)
+
+	case messageEvent:
+		if _, ok := s.connections[e.id]; ok {
+			s.handleMessage(e.id, e.msg)
+		}
+	}
+}
+
 func (s *Server) handleConnection(conn net.Conn) {
+	reader := message.NewFrameReader(conn, s.MaxFrameSize)
+
+	info, codec := handshake(conn, reader)
+	if info == nil {
+		conn.Close()
+		return
+	}
+
 	id := uuid.NewV4()
+
+	if !s.post(joinEvent{conn: newConnection(id, conn, codec), info: info}) {
+		conn.Close()
+		return
+	}
+
+	for {
+		bytes, err := reader.ReadFrame()
+
+		// An error will most likely occur because the
+		// connection was dropped, in which case the
+		// read loop is ended. It could also be that the
+		// client sent a frame which is too large, and
+		// the connection can't be trusted any more.
+		if err != nil {
+			if err != io.EOF {
+				fmt.Printf("reading from %s: %s\n", id, err.Error())
+			}
+
+			conn.Close()
+			s.post(leaveEvent{id: id})
+			break
+		}
+
+		msg, err := message.DeserializeWith(codec, bytes)
+		if err != nil {
+			fmt.Printf("deserializing: %s\n", err.Error())
+			continue
+		}
+
+		// A client sending a message only the server should
+		// send is either broken or up to no good.
+		if !message.Allowed(msg, message.ClientToServer) {
+			fmt.Printf("%s sent a %T, which only the server can send\n", id, msg)
+
+			conn.Close()
+			s.post(leaveEvent{id: id})
+			break
+		}
+
+		if !s.post(messageEvent{id: id, msg: msg}) {
+			break
+		}
+	}
+}
+
+func (s *Server) handleJoin(conn *connection, info *message.ClientInfo) {
+	// Players' positions are saved by name, so two players
+	// can't have the same one.
+	if s.nameTaken(info.Name) {
+		rejected, err := encode(message.JSON, &message.Rejected{
+			Reason: fmt.Sprintf("name taken: someone called %s is already playing", info.Name),
+		})
+
+		if err == nil {
+			conn.queue(rejected)
+		}
+
+		conn.close()
+
+		return
+	}
+
+	id := conn.id
 	s.connections[id] = conn
 
-	pos := s.World.FindFreeSpace()
+	pos, ok := s.savedPosition(info.Name)
+	if !ok {
+		pos = s.freeSpace()
+	}
 
 	// Create a new Ship for the connected player
 	player := entity.NewShip(pos.X, pos.Y)
+	player.Name = info.Name
 	s.Players[id] = player
 
+	// The rest of the handshake is always JSON, so
+	// the client can find out which codec to use.
+	accepted, err := encode(message.JSON, &message.Accepted{
+		Capabilities: message.CommonCapabilities(info.Capabilities),
+		Codec:        conn.codec.Name(),
+	})
+
+	if err != nil || !conn.queue(accepted) {
+		fmt.Println("in handleJoin: couldn't accept", id)
+	}
+
 	if err := s.Send(id, &message.GameInfo{
-		Tiles:   s.World.Tiles,
+		Width:   s.World.Width,
+		Height:  s.World.Height,
+		Seed:    s.World.Seed,
 		Players: s.abstractPlayers(),
 		ID:      id,
 	}); err != nil {
-		panic(err)
+		fmt.Println("in handleJoin:", err)
 	}
 
-	reader := bufio.NewReader(conn)
+	if err := s.streamWorld(conn, pos); err != nil {
+		fmt.Println("in handleJoin:", err)
+	}
 
-	for {
-		bytes, err := reader.ReadBytes(EOT)
+	err = s.Broadcast(&message.NewPlayer{
+		ID:     id,
+		Player: s.abstractPlayer(id, player),
+	})
 
-		// An error will most likely occur because the
-		// connection was dropped, in which case the
-		// read loop is ended.
-		if err != nil {
-			s.handleDisconnect(id)
-			break
-		}
+	if err != nil {
+		fmt.Println(err)
+	}
 
-		// Message received
-		if len(bytes) > 0 {
-			bytes = bytes[:len(bytes)-1]
+	fmt.Printf("%s joined the game\n", info.Name)
 
-			msg, err := message.Deserialize(bytes)
-			if err != nil {
-				fmt.Printf("deserializing: %s\n", err.Error())
-			}
+	s.Broadcast(&message.ChatMessage{
+		Time:    time.Now(),
+		Sender:  "server",
+		Content: fmt.Sprintf("%s joined the game\n", info.Name),
+		Type:    message.ServerChat,
+	})
+}
 
-			s.handleMessage(id, msg)
+// nameTaken checks whether a player with the given name
+// is already in the game.
+func (s *Server) nameTaken(name string) bool {
+	for _, ship := range s.Players {
+		if ship.Name == name {
+			return true
 		}
 	}
+
+	return false
 }
 
 func (s *Server) abstractPlayers() map[uuid.UUID]message.AbstractPlayer {
 	ap := make(map[uuid.UUID]message.AbstractPlayer)
 
 	for id, ship := range s.Players {
-		dest := ship.Pos
-
-		if len(ship.Path) > 0 {
-			dest = ship.Path[len(ship.Path)-1]
-		}
-
-		ap[id] = message.AbstractPlayer{
-			Position:    ship.Pos,
-			Destination: dest,
-		}
+		ap[id] = s.abstractPlayer(id, ship)
 	}
 
 	return ap
 }
 
+// Send queues a message to be sent to a client. It must
+// only be called from the event loop. If the client's
+// outbox is full, it's disconnected.
 func (s *Server) Send(id uuid.UUID, msg interface{}) error {
-	b, err := message.Serialize(msg)
+	conn, ok := s.connections[id]
+	if !ok {
+		return fmt.Errorf("no connection with id %s", id)
+	}
+
+	b, err := encode(conn.codec, msg)
 	if err != nil {
 		return err
 	}
 
-	b = append(b, EOT)
-	s.connections[id].Write(b)
+	if !conn.queue(b) {
+		// Closing the socket makes the client's read loop
+		// fail, which in turn removes it via a leaveEvent.
+		conn.conn.Close()
+		return fmt.Errorf("outbox full for %s", id)
+	}
 
 	return nil
 }
 
+// Broadcast queues a message to be sent to every client.
+// It must only be called from the event loop.
 func (s *Server) Broadcast(msg interface{}) error {
-	for id := range s.connections {
-		if err := s.Send(id, msg); err != nil {
-			return err
+	// Each message is only encoded once per codec,
+	// however many clients are using it.
+	encoded := make(map[message.Codec][]byte)
+
+	for id, conn := range s.connections {
+		b, ok := encoded[conn.codec]
+		if !ok {
+			var err error
+
+			if b, err = encode(conn.codec, msg); err != nil {
+				return err
+			}
+
+			encoded[conn.codec] = b
+		}
+
+		if !conn.queue(b) {
+			fmt.Printf("outbox full for %s, disconnecting\n", id)
+			conn.conn.Close()
 		}
 	}
 
 	return nil
 }
 
-func (s *Server) handleMessage(id uuid.UUID, msg interface{}) {
-	switch m := msg.(type) {
-	case *message.ClientInfo:
-		pos := s.Players[id].Pos
-		s.Players[id].Name = m.Name
-
-		err := s.Broadcast(&message.NewPlayer{
-			ID: id,
-			Player: message.AbstractPlayer{
-				Position:    pos,
-				Destination: pos,
-			},
-		})
+// streamWorld sends the world to a client one chunk at a
+// time, starting with the ones nearest to its ship.
+func (s *Server) streamWorld(conn *connection, from geom.Coord) error {
+	var frames [][]byte
 
+	for _, c := range s.World.ChunksByDistance(from) {
+		frame, err := s.chunkFrame(conn.codec, c)
 		if err != nil {
-			fmt.Println(err)
+			return err
 		}
 
-		fmt.Printf("%s joined the game\n", m.Name)
+		frames = append(frames, frame)
+	}
 
-		s.Broadcast(&message.ChatMessage{
-			Time:    time.Now(),
-			Sender:  "server",
-			Content: fmt.Sprintf("%s joined the game\n", m.Name),
-			Type:    game.ServerMessage,
-		})
+	if !conn.stream(frames) {
+		return fmt.Errorf("already streaming to %s", conn.id)
+	}
+
+	return nil
+}
 
+// chunkFrame returns a chunk's WorldChunk frame, only
+// encoding it if it's changed since it was last encoded.
+// The frames are shared between connections, so they
+// must never be modified.
+func (s *Server) chunkFrame(codec message.Codec, c world.ChunkCoord) ([]byte, error) {
+	var (
+		key     = chunkKey{codec: codec, chunk: c}
+		version = s.World.ChunkVersion(c)
+	)
+
+	if cached, ok := s.chunks[key]; ok && cached.version == version {
+		return cached.frame, nil
+	}
+
+	data, err := s.World.EncodeChunk(c)
+	if err != nil {
+		return nil, err
+	}
+
+	frame, err := encode(codec, &message.WorldChunk{
+		Chunk: c,
+		Data:  data,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.chunks[key] = &encodedChunk{version: version, frame: frame}
+
+	return frame, nil
+}
+
+// encode serializes a message into a frame.
+func encode(codec message.Codec, msg interface{}) ([]byte, error) {
+	b, err := message.SerializeWith(codec, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return message.EncodeFrame(b)
+}
+
+func (s *Server) handleMessage(id uuid.UUID, msg interface{}) {
+	switch m := msg.(type) {
 	case *message.Disconnect:
 		s.handleDisconnect(id)
 
 	case *message.Moved:
-		s.Players[id].Move(m.Position, s.World)
-
-		err := s.Broadcast(&message.PlayerMoved{
-			ID:       id,
-			Position: m.Position,
-		})
+		// Searching for the nearest tile to somewhere
+		// outside the world could take a very long time,
+		// so those moves are ignored.
+		if s.World.InBounds(int(m.Position.X), int(m.Position.Y)) {
+			s.moveShip(s.Players[id], m.Position)
+		} else {
+			fmt.Printf("%s tried to move outside the world, to %v\n", id, m.Position)
+		}
 
-		if err != nil {
-			fmt.Println(err)
+		// The move is acknowledged in the next snapshot
+		if m.Seq > s.acks[id] {
+			s.acks[id] = m.Seq
 		}
 
 	case *message.StateUpdate:
-		s.Players[id].Pos = m.Position
+		s.checkState(id, m.Position)
 
 	case *message.ChatMessage:
+		// The sender and type come from the server, so a
+		// client can't pretend to be someone else, or the
+		// server itself.
+		m.Sender = s.Players[id].Name
+		m.Type = message.GlobalChat
+
 		fmt.Printf("%s: %s\n", m.Sender, m.Content)
 
 		s.Broadcast(m)
 	}
 }
 
+// moveShip sets a ship sailing to a tile. If another ship
+// is already heading there, they share a flow field
+// rather than each searching for its own path, which
+// matters when lots of ships head for the same port.
+func (s *Server) moveShip(ship *entity.Ship, to geom.Coord) {
+	for _, other := range s.Players {
+		if other == ship || len(other.Path) == 0 || other.Destination() != to {
+			continue
+		}
+
+		if ship.Follow(s.World.FlowField(to)) {
+			return
+		}
+
+		break
+	}
+
+	ship.Move(to, s.World)
+}
+
 func (s *Server) handleDisconnect(id uuid.UUID) {
-	if s.closed[id] {
+	if _, ok := s.connections[id]; !ok {
 		return
 	}
 
-	s.closed[id] = true
+	name := s.Players[id].Name
+
+	// Close the connection and delete the player
+	s.removeConnection(id)
 
 	err := s.Broadcast(&message.PlayerLeft{
 		ID: id,
@@ -209,16 +604,64 @@ func (s *Server) handleDisconnect(id uuid.UUID) {
 		fmt.Println("in handleDisconnect:", err)
 	}
 
-	fmt.Printf("%s left the game\n", s.Players[id].Name)
+	fmt.Printf("%s left the game\n", name)
 
 	s.Broadcast(&message.ChatMessage{
 		Time:    time.Now(),
 		Sender:  "server",
-		Content: fmt.Sprintf("%s left the game\n", s.Players[id].Name),
-		Type:    game.ServerMessage,
+		Content: fmt.Sprintf("%s left the game\n", name),
+		Type:    message.ServerChat,
 	})
+}
 
-	// Close the connection and delete the player
-	s.connections[id].Close()
+// removeConnection stops a connection's write loop and
+// forgets about the player.
+func (s *Server) removeConnection(id uuid.UUID) {
+	s.connections[id].close()
+
+	if ship, ok := s.Players[id]; ok {
+		s.positions[ship.Name] = ship.Pos
+		s.traffic.forget(ship)
+	}
+
+	delete(s.connections, id)
 	delete(s.Players, id)
+	delete(s.acks, id)
+}
+
+// spawnAttempts is the amount of times the server tries
+// to find a tile for a new player which isn't already
+// taken by another ship.
+const spawnAttempts = 16
+
+// freeSpace finds somewhere for a new player to start,
+// avoiding other ships if it can.
+func (s *Server) freeSpace() geom.Coord {
+	var pos geom.Coord
+
+	for i := 0; i < spawnAttempts; i++ {
+		pos = s.World.FindFreeSpace()
+
+		if !s.occupied(pos) {
+			break
+		}
+	}
+
+	return pos
+}
+
+// occupied checks whether any ship is in, or moving
+// into, the tile at pos.
+func (s *Server) occupied(pos geom.Coord) bool {
+	for _, ship := range s.Players {
+		if ship.Pos == pos {
+			return true
+		}
+
+		if next, ok := ship.NextStep(); ok && ship.Moving() && next == pos {
+			return true
+		}
+	}
+
+	return false
 }
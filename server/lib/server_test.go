@@ -0,0 +1,485 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Zac-Garby/pieces-of-seven/entity"
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+	"github.com/Zac-Garby/pieces-of-seven/message"
+	"github.com/Zac-Garby/pieces-of-seven/world"
+	"github.com/satori/go.uuid"
+)
+
+// stressClients is how many clients TestStress connects
+// at once, and stressRounds is how many times each of
+// them moves and chats.
+const (
+	stressClients = 50
+	stressRounds  = 5
+)
+
+// testTimeout is how long a test client waits for the
+// server before giving up. It's generous, since the
+// race detector slows the server down a lot.
+const testTimeout = 30 * time.Second
+
+// testConfig is the config of the test servers, which
+// have small worlds so they start quickly.
+func testConfig() Config {
+	gen := world.DefaultGeneratorConfig
+	gen.Width, gen.Height = 64, 64
+
+	return Config{Seed: 1, Generator: gen}
+}
+
+func newTestServer(t *testing.T, cfg Config) (*Server, net.Listener) {
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Serve(ln)
+
+	return s, ln
+}
+
+// A testClient is a player talking to a test server.
+type testClient struct {
+	conn   net.Conn
+	reader *message.FrameReader
+	codec  message.Codec
+	id     uuid.UUID
+
+	// pos is where the client's ship started.
+	pos geom.Coord
+}
+
+// join connects to the server and does the handshake,
+// returning once the client has been sent its GameInfo.
+func join(addr, name, codec string) (*testClient, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(testTimeout))
+
+	c := &testClient{
+		conn:   conn,
+		reader: message.NewFrameReader(conn, message.DefaultMaxFrameSize),
+		codec:  message.JSON,
+	}
+
+	err = c.send(&message.ClientInfo{
+		Name:    name,
+		Version: message.ProtocolVersion,
+		Codecs:  []string{codec},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	accepted, ok := msg.(*message.Accepted)
+	if !ok {
+		return nil, fmt.Errorf("expected Accepted, got %T", msg)
+	}
+
+	if c.codec, ok = message.GetCodec(accepted.Codec); !ok {
+		return nil, fmt.Errorf("unknown codec %q", accepted.Codec)
+	}
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+
+		if info, ok := msg.(*message.GameInfo); ok {
+			c.id = info.ID
+			c.pos = info.Players[info.ID].Position
+
+			return c, nil
+		}
+	}
+}
+
+func (c *testClient) send(msg interface{}) error {
+	b, err := message.SerializeWith(c.codec, msg)
+	if err != nil {
+		return err
+	}
+
+	return message.WriteFrame(c.conn, b)
+}
+
+func (c *testClient) read() (interface{}, error) {
+	b, err := c.reader.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	return message.DeserializeWith(c.codec, b)
+}
+
+// play moves around, chats, and then leaves, returning
+// once the server has closed the connection.
+func (c *testClient) play(name string) error {
+	closed := make(chan error, 1)
+
+	go func() {
+		for {
+			if _, err := c.read(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < stressRounds; i++ {
+		err := c.send(&message.Moved{
+			Position: geom.Coord{X: uint(i * 12), Y: uint(i * 6)},
+			Seq:      uint32(i + 1),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		err = c.send(&message.ChatMessage{
+			Time:    time.Now(),
+			Sender:  name,
+			Content: "ahoy",
+			Type:    message.GlobalChat,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := c.send(&message.Disconnect{}); err != nil {
+		return err
+	}
+
+	select {
+	case <-closed:
+		return nil
+	case <-time.After(testTimeout):
+		return fmt.Errorf("%s was never disconnected", name)
+	}
+}
+
+func TestStress(t *testing.T) {
+	s, ln := newTestServer(t, testConfig())
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids = make(map[uuid.UUID]bool)
+	)
+
+	for i := 0; i < stressClients; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			var (
+				name  = fmt.Sprintf("player%d", i)
+				codec = message.Codecs[i%len(message.Codecs)].Name()
+			)
+
+			c, err := join(ln.Addr().String(), name, codec)
+			if err != nil {
+				t.Errorf("%s joining: %s", name, err)
+				return
+			}
+
+			defer c.conn.Close()
+
+			mu.Lock()
+			ids[c.id] = true
+			mu.Unlock()
+
+			if err := c.play(name); err != nil {
+				t.Errorf("%s playing: %s", name, err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if len(ids) != stressClients && !t.Failed() {
+		t.Errorf("%d clients joined with %d different ids", stressClients, len(ids))
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the event loop has stopped, its state can be
+	// looked at safely.
+	if len(s.Players) != 0 || len(s.connections) != 0 {
+		t.Errorf("%d players and %d connections left over", len(s.Players), len(s.connections))
+	}
+}
+
+// hello sends a ClientInfo to the server, and returns
+// the first message it replies with.
+func hello(addr string, info *message.ClientInfo) (interface{}, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(testTimeout))
+
+	c := &testClient{
+		conn:   conn,
+		reader: message.NewFrameReader(conn, message.DefaultMaxFrameSize),
+		codec:  message.JSON,
+	}
+
+	if err := c.send(info); err != nil {
+		return nil, err
+	}
+
+	return c.read()
+}
+
+func TestReject(t *testing.T) {
+	s, ln := newTestServer(t, testConfig())
+	defer s.Close()
+
+	addr := ln.Addr().String()
+
+	anne, err := join(addr, "anne", message.JSON.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer anne.conn.Close()
+
+	tests := []struct {
+		name string
+		info *message.ClientInfo
+	}{
+		{"old version", &message.ClientInfo{Name: "old", Version: message.ProtocolVersion - 1}},
+		{"no name", &message.ClientInfo{Name: "", Version: message.ProtocolVersion}},
+		{"blank name", &message.ClientInfo{Name: "  ", Version: message.ProtocolVersion}},
+		{"taken name", &message.ClientInfo{Name: "anne", Version: message.ProtocolVersion}},
+	}
+
+	for _, test := range tests {
+		msg, err := hello(addr, test.info)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if _, ok := msg.(*message.Rejected); !ok {
+			t.Errorf("%s: expected Rejected, got %T", test.name, msg)
+		}
+	}
+
+	// Once anne has left, the name is free again.
+	if err := anne.send(&message.Disconnect{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		if _, err := anne.read(); err != nil {
+			break
+		}
+	}
+
+	again, err := join(addr, "anne", message.JSON.Name())
+	if err != nil {
+		t.Fatalf("couldn't join again after leaving: %s", err)
+	}
+
+	again.conn.Close()
+}
+
+func TestChunkFrameCache(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	// Once the event loop has stopped, chunkFrame can be
+	// called from here.
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := world.ChunkCoord{X: 1, Y: 1}
+
+	frame := func(codec message.Codec) []byte {
+		b, err := s.chunkFrame(codec, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return b
+	}
+
+	var (
+		first  = frame(message.JSON)
+		second = frame(message.JSON)
+	)
+
+	if &first[0] != &second[0] {
+		t.Error("an unchanged chunk was encoded again")
+	}
+
+	if binary := frame(message.Binary); bytes.Equal(binary, first) {
+		t.Error("the same frame was used for both codecs")
+	}
+
+	x0, y0, _, _ := s.World.ChunkBounds(c)
+	s.World.SetTile(x0, y0, world.Rock)
+
+	changed := frame(message.JSON)
+	if bytes.Equal(changed, first) {
+		t.Fatal("a changed chunk wasn't encoded again")
+	}
+
+	want, err := s.World.EncodeChunk(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := message.NewFrameReader(bytes.NewReader(changed), message.DefaultMaxFrameSize).ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := message.DeserializeWith(message.JSON, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := msg.(*message.WorldChunk); !ok || got.Chunk != c || !bytes.Equal(got.Data, want) {
+		t.Errorf("expected the changed chunk, got %v", msg)
+	}
+}
+
+func TestMoveShipFlowField(t *testing.T) {
+	w := craftWorld(
+		"..........",
+		"...####...",
+		"..........",
+	)
+
+	var (
+		first  = entity.NewShip(0, 0)
+		second = entity.NewShip(0, 2)
+		to     = geom.Coord{X: 9, Y: 1}
+
+		s = &Server{
+			World:   w,
+			Players: map[uuid.UUID]*entity.Ship{uuid.NewV4(): first, uuid.NewV4(): second},
+		}
+	)
+
+	s.moveShip(first, to)
+
+	if first.Destination() != to {
+		t.Fatalf("expected the first ship to head to %v, got %v", to, first.Path)
+	}
+
+	s.moveShip(second, to)
+
+	path, _ := w.FlowField(to).Path(second.Pos)
+	if !reflect.DeepEqual([]geom.Coord(second.Path), path) {
+		t.Errorf("expected the second ship to follow the flow field, %v, got %v", path, second.Path)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	s, _ := newTestServer(t, testConfig())
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := s.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChatSender(t *testing.T) {
+	s, ln := newTestServer(t, testConfig())
+	defer s.Close()
+
+	addr := ln.Addr().String()
+
+	anne, err := join(addr, "anne", message.JSON.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer anne.conn.Close()
+
+	bob, err := join(addr, "bob", message.Binary.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer bob.conn.Close()
+
+	err = anne.send(&message.ChatMessage{
+		Time:    time.Now(),
+		Sender:  "server",
+		Content: "free gold",
+		Type:    message.ServerChat,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		msg, err := bob.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if chat, ok := msg.(*message.ChatMessage); ok && chat.Content == "free gold" {
+			if chat.Sender != "anne" || chat.Type != message.GlobalChat {
+				t.Errorf("expected a global chat from anne, got a type %d chat from %s", chat.Type, chat.Sender)
+			}
+
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Zac-Garby/pieces-of-seven/entity"
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+	"github.com/Zac-Garby/pieces-of-seven/message"
+	"github.com/satori/go.uuid"
+)
+
+// TickRate is the amount of times per second the
+// server advances its simulation.
+const TickRate = 20
+
+// SnapshotInterval is the amount of ticks between
+// each Snapshot broadcast to the clients.
+const SnapshotInterval = 2
+
+// tick advances every ship along its path by one tick's
+// worth of movement. The server's ships are the source
+// of truth for where everyone is.
+func (s *Server) tick() {
+	s.ticks++
+
+	s.traffic.step(s.ships(), s.World, 1.0/TickRate)
+
+	if s.ticks%SnapshotInterval == 0 && len(s.connections) > 0 {
+		if err := s.Broadcast(&message.Snapshot{
+			Tick:    s.ticks,
+			Players: s.abstractPlayers(),
+		}); err != nil {
+			fmt.Println("in tick:", err)
+		}
+	}
+}
+
+// ships returns every ship in the game, always in the
+// same order so ties between them are settled fairly.
+func (s *Server) ships() []*entity.Ship {
+	ids := make([]string, 0, len(s.Players))
+	byID := make(map[string]*entity.Ship, len(s.Players))
+
+	for id, ship := range s.Players {
+		ids = append(ids, id.String())
+		byID[id.String()] = ship
+	}
+
+	sort.Strings(ids)
+
+	ships := make([]*entity.Ship, len(ids))
+	for i, id := range ids {
+		ships[i] = byID[id]
+	}
+
+	return ships
+}
+
+// checkState compares the position a client claims to
+// be at with the server's, and corrects the client if
+// they're too far apart.
+func (s *Server) checkState(id uuid.UUID, pos geom.Coord) {
+	ship := s.Players[id]
+
+	claimed := geom.Vector{X: float64(pos.X), Y: float64(pos.Y)}
+	if ship.Distance(claimed) <= entity.SyncTolerance {
+		return
+	}
+
+	if err := s.Send(id, &message.Correction{
+		Player: s.abstractPlayer(id, ship),
+	}); err != nil {
+		fmt.Println("in checkState:", err)
+	}
+}
+
+func (s *Server) abstractPlayer(id uuid.UUID, ship *entity.Ship) message.AbstractPlayer {
+	return message.AbstractPlayer{
+		Position:    ship.Pos,
+		ApparentPos: ship.ApparentPos,
+		Destination: ship.Destination(),
+		Seq:         s.acks[id],
+	}
+}
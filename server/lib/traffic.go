@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"github.com/Zac-Garby/pieces-of-seven/entity"
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+	"github.com/Zac-Garby/pieces-of-seven/world"
+)
+
+// RerouteAfter is the amount of ticks a ship waits for
+// another one to get out of its way before it looks for
+// a way around it.
+const RerouteAfter = TickRate / 2
+
+// GiveUpAfter is the amount of ticks a ship waits before
+// it gives up and stops where it is.
+const GiveUpAfter = TickRate * 5
+
+// traffic keeps ships from sailing through each other.
+// Each ship occupies the tile it's in, and reserves the
+// next tile on its path before it starts to move into
+// it. A ship can't move into a tile which another one is
+// occupying or has reserved, so it waits, then tries to
+// find a way around, and eventually gives up.
+//
+// It works with any ships, so it'll apply to computer
+// controlled ones just as well as players.
+type traffic struct {
+	waits map[*entity.Ship]int
+}
+
+func newTraffic() *traffic {
+	return &traffic{
+		waits: make(map[*entity.Ship]int),
+	}
+}
+
+// step moves the ships along their paths by dt seconds,
+// in the given order. Ships earlier in the order get to
+// move first when two want the same tile.
+func (t *traffic) step(ships []*entity.Ship, w *world.World, dt float64) {
+	occupied := make(map[geom.Coord]*entity.Ship)
+
+	for _, ship := range ships {
+		occupied[ship.Pos] = ship
+	}
+
+	// Ships which are already between two tiles have
+	// reserved the one they're moving into, so they always
+	// carry on.
+	for _, ship := range ships {
+		if next, ok := ship.NextStep(); ok && ship.Moving() {
+			occupied[next] = ship
+		}
+	}
+
+	for _, ship := range ships {
+		next, ok := ship.NextStep()
+		if !ok {
+			delete(t.waits, ship)
+			continue
+		}
+
+		if other, taken := occupied[next]; taken && other != ship && !ship.Moving() {
+			t.blocked(ship, other, occupied, w)
+			continue
+		}
+
+		occupied[next] = ship
+		delete(t.waits, ship)
+
+		ship.Update(dt)
+
+		// If the ship reached the tile it reserved and
+		// carried on towards the one after, that tile needs
+		// reserving too, or it has to stop where it is.
+		if after, ok := ship.NextStep(); ok && ship.Moving() && after != next {
+			if other, taken := occupied[after]; taken && other != ship {
+				ship.ApparentPos = geom.Vector{X: float64(ship.Pos.X), Y: float64(ship.Pos.Y)}
+			} else {
+				occupied[after] = ship
+			}
+		}
+	}
+}
+
+// forget stops keeping track of a ship, such as when its
+// player leaves.
+func (t *traffic) forget(ship *entity.Ship) {
+	delete(t.waits, ship)
+}
+
+// blocked is called when a ship can't move because
+// another one is in the way.
+func (t *traffic) blocked(ship, other *entity.Ship, occupied map[geom.Coord]*entity.Ship, w *world.World) {
+	next, _ := ship.NextStep()
+
+	// If the other ship is parked at this one's
+	// destination, there's no point waiting for it.
+	if next == ship.Destination() && !other.Moving() {
+		if _, moving := other.NextStep(); !moving {
+			ship.Path = nil
+			delete(t.waits, ship)
+
+			return
+		}
+	}
+
+	t.waits[ship]++
+	waited := t.waits[ship]
+
+	if waited >= GiveUpAfter {
+		ship.Path = nil
+		delete(t.waits, ship)
+
+		return
+	}
+
+	if waited%RerouteAfter != 0 {
+		return
+	}
+
+	path := []geom.Coord(ship.Path)
+
+	if len(path) == 0 || path[0] != ship.Pos {
+		path = append([]geom.Coord{ship.Pos}, path...)
+	}
+
+	detour, ok := w.FindDetour(path, func(pos geom.Coord) bool {
+		s, taken := occupied[pos]
+		return taken && s != ship
+	})
+
+	if ok {
+		ship.Path = entity.Path(detour)
+	}
+}
@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/entity"
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+	"github.com/Zac-Garby/pieces-of-seven/world"
+)
+
+// craftWorld makes a World from rows of characters, with
+// '#' for Land and anything else for Water.
+func craftWorld(rows ...string) *world.World {
+	w := world.New(len(rows[0]), len(rows))
+
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				w.Tiles[y][x] = world.Land
+			}
+		}
+	}
+
+	w.MakeGraph()
+
+	return w
+}
+
+// sail makes ships at each of from, and sets them off
+// towards the matching coordinate in to.
+func sail(w *world.World, from, to []geom.Coord) []*entity.Ship {
+	ships := make([]*entity.Ship, len(from))
+
+	for i, pos := range from {
+		ships[i] = entity.NewShip(pos.X, pos.Y)
+		ships[i].Move(to[i], w)
+	}
+
+	return ships
+}
+
+// simulate steps the traffic for the given amount of
+// ticks, failing the test if two ships ever share a tile.
+// It returns every tile each ship was in.
+func simulate(t *testing.T, w *world.World, ships []*entity.Ship, ticks int) []map[geom.Coord]bool {
+	var (
+		tr      = newTraffic()
+		visited = make([]map[geom.Coord]bool, len(ships))
+	)
+
+	for i := range visited {
+		visited[i] = make(map[geom.Coord]bool)
+	}
+
+	for tick := 0; tick < ticks; tick++ {
+		tr.step(ships, w, 1.0/TickRate)
+
+		seen := make(map[geom.Coord]int)
+
+		for i, ship := range ships {
+			visited[i][ship.Pos] = true
+
+			if other, ok := seen[ship.Pos]; ok {
+				t.Fatalf("tick %d: ships %d and %d are both at %v", tick, other, i, ship.Pos)
+			}
+
+			seen[ship.Pos] = i
+		}
+	}
+
+	return visited
+}
+
+func TestTrafficChannel(t *testing.T) {
+	w := craftWorld(
+		"####################",
+		"....................",
+		"####################",
+	)
+
+	var (
+		from = []geom.Coord{{X: 0, Y: 1}, {X: 19, Y: 1}}
+		to   = []geom.Coord{{X: 19, Y: 1}, {X: 0, Y: 1}}
+	)
+
+	ships := sail(w, from, to)
+
+	// They meet after about three seconds, and can't get
+	// past each other, but they shouldn't give up until
+	// they've waited GiveUpAfter ticks.
+	simulate(t, w, ships, TickRate*3+GiveUpAfter/2)
+
+	for i, ship := range ships {
+		if len(ship.Path) == 0 {
+			t.Fatalf("ship %d gave up too soon, at %v", i, ship.Pos)
+		}
+	}
+
+	simulate(t, w, ships, GiveUpAfter)
+
+	for i, ship := range ships {
+		if len(ship.Path) != 0 || ship.Pos == to[i] {
+			t.Errorf("expected ship %d to give up on the way, but it's at %v with %d steps to go", i, ship.Pos, len(ship.Path))
+		}
+	}
+}
+
+func TestTrafficDetour(t *testing.T) {
+	w := craftWorld(
+		"####################",
+		"#####..........#####",
+		"....................",
+		"####################",
+	)
+
+	var (
+		from = []geom.Coord{{X: 0, Y: 2}, {X: 19, Y: 2}}
+		to   = []geom.Coord{{X: 19, Y: 2}, {X: 0, Y: 2}}
+	)
+
+	ships := sail(w, from, to)
+	visited := simulate(t, w, ships, TickRate*20)
+
+	for i, ship := range ships {
+		if ship.Pos != to[i] || len(ship.Path) != 0 {
+			t.Errorf("expected ship %d to reach %v, but it's at %v", i, to[i], ship.Pos)
+		}
+	}
+
+	// The straight route is along the bottom row, so one
+	// of them must have used the side route to get past.
+	detoured := false
+
+	for _, tiles := range visited {
+		for pos := range tiles {
+			detoured = detoured || pos.Y == 1
+		}
+	}
+
+	if !detoured {
+		t.Error("neither ship took the side route")
+	}
+}
+
+func TestTrafficParked(t *testing.T) {
+	w := craftWorld(
+		"............",
+		"............",
+		"............",
+	)
+
+	var (
+		ships = sail(w, []geom.Coord{{X: 0, Y: 1}}, []geom.Coord{{X: 8, Y: 1}})
+		other = entity.NewShip(8, 1)
+	)
+
+	// There's no point waiting for a ship parked at the
+	// destination, so the ship stops next to it.
+	simulate(t, w, append(ships, other), RerouteAfter+TickRate*5)
+
+	if ship := ships[0]; len(ship.Path) != 0 || ship.Pos != (geom.Coord{X: 7, Y: 1}) {
+		t.Errorf("expected the ship to stop at (7, 1), but it's at %v with %d steps to go", ship.Pos, len(ship.Path))
+	}
+}
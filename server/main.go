@@ -2,16 +2,40 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/Zac-Garby/pieces-of-seven/server/lib"
+	"github.com/Zac-Garby/pieces-of-seven/world"
 )
 
 const DefaultPort = "12358"
 
+var (
+	algorithm  = flag.String("generator", string(world.DefaultGeneratorConfig.Algorithm), "the algorithm used to generate the world: cellular or noise")
+	seed       = flag.Int64("seed", 0, "the seed to generate the world from, or 0 for a random one")
+	width      = flag.Int("width", world.DefaultGeneratorConfig.Width, "the width of the world, in tiles")
+	height     = flag.Int("height", world.DefaultGeneratorConfig.Height, "the height of the world, in tiles")
+	landRatio  = flag.Float64("land", world.DefaultGeneratorConfig.LandRatio, "the chance, from 0 to 1, of each tile starting as land")
+	smoothing  = flag.Int("smoothing", world.DefaultGeneratorConfig.SmoothingRadius, "the radius of the smoothing applied to the terrain")
+	iterations = flag.Int("iterations", world.DefaultGeneratorConfig.Iterations, "the amount of times the terrain is smoothed")
+	scale      = flag.Float64("scale", world.DefaultGeneratorConfig.NoiseScale, "the size, in tiles, of the largest features made by the noise generator")
+	octaves    = flag.Int("octaves", world.DefaultGeneratorConfig.Octaves, "the amount of layers of noise used by the noise generator")
+	spawn      = flag.String("spawn", "", "the area where players start, as x,y,width,height, or empty for anywhere")
+	worldPath  = flag.String("world", "", "the file to save the world to, and load it from if it exists")
+	interval   = flag.Duration("save-interval", lib.DefaultSaveInterval, "how often the world is saved")
+	mapPath    = flag.String("map", "", "a PNG map image or Tiled map to import the world from, instead of generating it")
+	overview   = flag.String("overview", "", "a file to write a PNG overview of the world to")
+	palette    = flag.String("palette", "", "a JSON file mapping tile names to their colours in map images")
+)
+
 func main() {
+	flag.Parse()
+
 	fmt.Printf("server's port [%s]? :", DefaultPort)
 
 	reader := bufio.NewReader(os.Stdin)
@@ -28,8 +52,87 @@ func main() {
 
 	port = ":" + port
 
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	cfg := lib.Config{
+		Address: port,
+		Seed:    *seed,
+		Generator: world.GeneratorConfig{
+			Algorithm:       world.Algorithm(*algorithm),
+			Width:           *width,
+			Height:          *height,
+			LandRatio:       *landRatio,
+			SmoothingRadius: *smoothing,
+			Iterations:      *iterations,
+			NoiseScale:      *scale,
+			Octaves:         *octaves,
+		},
+		WorldPath:    *worldPath,
+		SaveInterval: *interval,
+		MapPath:      *mapPath,
+		OverviewPath: *overview,
+	}
+
+	if *spawn != "" {
+		zone, err := world.ParseZone(*spawn)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		cfg.SpawnZone = &zone
+	}
+
+	if *palette != "" {
+		p, err := world.LoadPalette(*palette)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		cfg.Palette = p
+	}
+
+	server, err := lib.New(cfg)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if server.Loaded {
+		fmt.Println("loaded world from", *worldPath)
+	}
+
+	// The seed is printed so the world can be
+	// generated again, for example in a bug report.
+	fmt.Println("world seed:", server.World.Seed)
+
+	// The world is saved one last time when the server
+	// is stopped, so stopped is only closed once that's
+	// done.
+	var (
+		interrupt = make(chan os.Signal, 1)
+		stopped   = make(chan struct{})
+	)
+
+	signal.Notify(interrupt, os.Interrupt)
+
+	go func() {
+		<-interrupt
+		server.Close()
+		close(stopped)
+	}()
+
 	fmt.Println("listening on", port)
 
-	server := lib.New(port)
-	fmt.Println(server.Listen())
+	if err := server.Listen(); err != lib.ErrServerClosed {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	<-stopped
+
+	fmt.Println("server stopped")
 }
@@ -0,0 +1,161 @@
+package world
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// These thresholds decide which biome a tile is in.
+// Heights and depths are measured from sea level, from
+// 0 at the coast to 1 at the highest or deepest point.
+const (
+	// Tiles deeper than deepWaterDepth are DeepWater.
+	deepWaterDepth = 0.3
+
+	// Shallow tiles shallower than reefDepth, and wetter
+	// than reefMoisture, are Reefs.
+	reefDepth    = 0.15
+	reefMoisture = 0.65
+
+	// Land lower than beachHeight is Beach.
+	beachHeight = 0.06
+
+	// Land higher than rockHeight is Rock.
+	rockHeight = 0.6
+
+	// Land wetter than forestMoisture is Forest, and
+	// the rest is Grassland.
+	forestMoisture = 0.55
+
+	// edgeFalloff is the proportion of the world's size,
+	// from each edge, over which the land sinks into the
+	// sea, so the world is always surrounded by water.
+	edgeFalloff = 0.1
+)
+
+// generateNoise fills the world with biomes. Two
+// layered noise maps are made: one for elevation and
+// one for moisture. Sea level is chosen so that
+// LandRatio of the world is above it. Water is deep or
+// shallow depending on the elevation, and land is
+// beach, grassland, forest or rock depending on both
+// elevation and moisture. Reefs grow in wet shallows.
+func generateNoise(w *World, rng *rand.Rand, cfg GeneratorConfig) {
+	var (
+		elevation = newNoise(rng)
+		moisture  = newNoise(rng)
+
+		// The noise is offset, so different seeds don't
+		// all start with the same value at (0, 0).
+		ox = rng.Float64() * 256
+		oy = rng.Float64() * 256
+
+		heights = make([][]float64, cfg.Height)
+		sorted  = make([]float64, 0, cfg.Width*cfg.Height)
+	)
+
+	for y := 0; y < cfg.Height; y++ {
+		heights[y] = make([]float64, cfg.Width)
+
+		for x := 0; x < cfg.Width; x++ {
+			var (
+				nx = float64(x)/cfg.NoiseScale + ox
+				ny = float64(y)/cfg.NoiseScale + oy
+				h  = elevation.fractal(nx, ny, cfg.Octaves) * falloff(x, y, cfg.Width, cfg.Height)
+			)
+
+			heights[y][x] = h
+			sorted = append(sorted, h)
+		}
+	}
+
+	sort.Float64s(sorted)
+
+	var (
+		lowest  = sorted[0]
+		highest = sorted[len(sorted)-1]
+		sea     = seaLevel(sorted, cfg.LandRatio)
+	)
+
+	for y := 0; y < cfg.Height; y++ {
+		for x := 0; x < cfg.Width; x++ {
+			var (
+				h = heights[y][x]
+				m = moisture.fractal(float64(x)/cfg.NoiseScale+oy, float64(y)/cfg.NoiseScale+ox, cfg.Octaves)
+			)
+
+			if h > sea {
+				w.Tiles[y][x] = landBiome((h-sea)/(highest-sea), m)
+			} else {
+				w.Tiles[y][x] = waterBiome((sea-h)/(sea-lowest+1e-9), m)
+			}
+		}
+	}
+}
+
+// seaLevel returns the height above which the given
+// proportion of the sorted heights lie.
+func seaLevel(sorted []float64, landRatio float64) float64 {
+	if landRatio <= 0 {
+		return sorted[len(sorted)-1]
+	}
+
+	i := int(float64(len(sorted)) * (1 - landRatio))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+
+	// Anything exactly at sea level is water, so go
+	// just under the i'th height.
+	return math.Nextafter(sorted[i], math.Inf(-1))
+}
+
+// falloff returns a multiplier for the elevation at
+// (x, y), which drops to 0 at the edges of the world.
+func falloff(x, y, width, height int) float64 {
+	var (
+		dx   = math.Min(float64(x), float64(width-1-x))
+		dy   = math.Min(float64(y), float64(height-1-y))
+		band = edgeFalloff * math.Min(float64(width), float64(height))
+	)
+
+	if band < 1 {
+		return 1
+	}
+
+	return math.Min(1, math.Min(dx, dy)/band)
+}
+
+// landBiome decides which land tile to use for a
+// given height and moisture.
+func landBiome(height, moisture float64) Tile {
+	switch {
+	case height < beachHeight:
+		return Beach
+
+	case height > rockHeight:
+		return Rock
+
+	case moisture > forestMoisture:
+		return Forest
+
+	default:
+		return Grassland
+	}
+}
+
+// waterBiome decides which water tile to use for a
+// given depth and moisture.
+func waterBiome(depth, moisture float64) Tile {
+	switch {
+	case depth > deepWaterDepth:
+		return DeepWater
+
+	case depth < reefDepth && moisture > reefMoisture:
+		return Reef
+
+	default:
+		return ShallowWater
+	}
+}
@@ -0,0 +1,279 @@
+package world
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// ChunkSize is the width and height, in Tiles, of a
+// chunk. Worlds are sent to clients one chunk at a time.
+const ChunkSize = 16
+
+// ErrBadChunk is returned when a chunk's data can't
+// be decoded.
+var ErrBadChunk = errors.New("bad chunk data")
+
+// A ChunkCoord is the position of a chunk, measured
+// in chunks rather than Tiles.
+type ChunkCoord struct {
+	X, Y int
+}
+
+// NewUnloaded creates a World whose tiles haven't been
+// received yet. Chunks are added with LoadChunk, and
+// until then IsLoaded reports them as missing.
+func NewUnloaded(width, height int) *World {
+	world := New(width, height)
+	world.loaded = make([][]bool, world.ChunksHigh())
+
+	for y := range world.loaded {
+		world.loaded[y] = make([]bool, world.ChunksWide())
+	}
+
+	return world
+}
+
+// ChunksWide returns the amount of chunks across the World.
+func (w *World) ChunksWide() int {
+	return (w.Width + ChunkSize - 1) / ChunkSize
+}
+
+// ChunksHigh returns the amount of chunks down the World.
+func (w *World) ChunksHigh() int {
+	return (w.Height + ChunkSize - 1) / ChunkSize
+}
+
+// ChunkBounds returns the area of the World, in Tiles,
+// covered by a chunk. Chunks on the right and bottom
+// edges can be smaller than ChunkSize.
+func (w *World) ChunkBounds(c ChunkCoord) (x0, y0, x1, y1 int) {
+	x0, y0 = c.X*ChunkSize, c.Y*ChunkSize
+	x1, y1 = x0+ChunkSize, y0+ChunkSize
+
+	if x1 > w.Width {
+		x1 = w.Width
+	}
+
+	if y1 > w.Height {
+		y1 = w.Height
+	}
+
+	return
+}
+
+// ValidChunk checks that a chunk is inside the World.
+func (w *World) ValidChunk(c ChunkCoord) bool {
+	return c.X >= 0 && c.Y >= 0 && c.X < w.ChunksWide() && c.Y < w.ChunksHigh()
+}
+
+// ChunkVersion returns a number which changes whenever
+// the chunk's tiles are changed with SetTile or LoadChunk,
+// so a renderer can tell when to redraw it.
+func (w *World) ChunkVersion(c ChunkCoord) uint64 {
+	if w.versions == nil || !w.ValidChunk(c) {
+		return 0
+	}
+
+	return w.versions[c.Y*w.ChunksWide()+c.X]
+}
+
+// touchChunk changes the version of the chunk
+// containing the tile at (x, y).
+func (w *World) touchChunk(x, y int) {
+	if w.versions == nil {
+		w.versions = make([]uint64, w.ChunksWide()*w.ChunksHigh())
+	}
+
+	w.versions[(y/ChunkSize)*w.ChunksWide()+x/ChunkSize]++
+}
+
+// ChunksByDistance returns the coordinates of every chunk
+// in the world, nearest to the given tile first.
+func (w *World) ChunksByDistance(from geom.Coord) []ChunkCoord {
+	var (
+		coords = make([]ChunkCoord, 0, w.ChunksWide()*w.ChunksHigh())
+		fx     = int(from.X) / ChunkSize
+		fy     = int(from.Y) / ChunkSize
+	)
+
+	for y := 0; y < w.ChunksHigh(); y++ {
+		for x := 0; x < w.ChunksWide(); x++ {
+			coords = append(coords, ChunkCoord{X: x, Y: y})
+		}
+	}
+
+	dist := func(c ChunkCoord) int {
+		return (c.X-fx)*(c.X-fx) + (c.Y-fy)*(c.Y-fy)
+	}
+
+	sort.SliceStable(coords, func(i, j int) bool {
+		return dist(coords[i]) < dist(coords[j])
+	})
+
+	return coords
+}
+
+// EncodeChunk run-length encodes the tiles in a chunk,
+// row by row. The encoding is a sequence of runs, each
+// being a uvarint count followed by a uvarint Tile.
+func (w *World) EncodeChunk(c ChunkCoord) ([]byte, error) {
+	if !w.ValidChunk(c) {
+		return nil, fmt.Errorf("chunk %d, %d is outside the world", c.X, c.Y)
+	}
+
+	var (
+		data    []byte
+		scratch [binary.MaxVarintLen64]byte
+		run     uint64
+		current Tile
+	)
+
+	flush := func() {
+		n := binary.PutUvarint(scratch[:], run)
+		data = append(data, scratch[:n]...)
+
+		n = binary.PutUvarint(scratch[:], uint64(current))
+		data = append(data, scratch[:n]...)
+	}
+
+	x0, y0, x1, y1 := w.ChunkBounds(c)
+
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			tile := w.Tiles[y][x]
+
+			if run > 0 && tile != current {
+				flush()
+				run = 0
+			}
+
+			current = tile
+			run++
+		}
+	}
+
+	flush()
+
+	return data, nil
+}
+
+// LoadChunk decodes a chunk encoded by EncodeChunk into
+// the world, updating the path-finding graph to match.
+func (w *World) LoadChunk(c ChunkCoord, data []byte) error {
+	if !w.ValidChunk(c) {
+		return fmt.Errorf("chunk %d, %d is outside the world", c.X, c.Y)
+	}
+
+	var (
+		x0, y0, x1, y1 = w.ChunkBounds(c)
+		width          = x1 - x0
+		total          = width * (y1 - y0)
+		tiles          = make([]Tile, 0, total)
+	)
+
+	for len(data) > 0 {
+		run, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrBadChunk
+		}
+
+		data = data[n:]
+
+		id, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrBadChunk
+		}
+
+		data = data[n:]
+
+		tile := Tile(id)
+		if tile.GetData() == nil {
+			return fmt.Errorf("unknown tile %d in chunk %d, %d", id, c.X, c.Y)
+		}
+
+		if run > uint64(total-len(tiles)) {
+			return ErrBadChunk
+		}
+
+		for i := uint64(0); i < run; i++ {
+			tiles = append(tiles, tile)
+		}
+	}
+
+	if len(tiles) != total {
+		return ErrBadChunk
+	}
+
+	// The tiles are written directly rather than with
+	// SetTile, so the regions and flow fields are only
+	// thrown away once for the whole chunk.
+	changed := false
+
+	for i, tile := range tiles {
+		x, y := x0+i%width, y0+i/width
+
+		if w.Tiles[y][x] == tile {
+			continue
+		}
+
+		w.Tiles[y][x] = tile
+		changed = true
+
+		if node := w.Graph.At(x, y); node != nil {
+			node.Tile = tile
+		}
+
+		if w.Hierarchy != nil {
+			w.Hierarchy.Invalidate(x, y)
+		}
+	}
+
+	if changed {
+		w.regions = nil
+		w.invalidateFlowFields()
+		w.touchChunk(x0, y0)
+	}
+
+	if w.loaded != nil {
+		w.loaded[c.Y][c.X] = true
+	}
+
+	return nil
+}
+
+// IsLoaded checks whether the chunk containing the tile
+// at (x, y) has been loaded. Worlds which weren't made
+// with NewUnloaded are always loaded.
+func (w *World) IsLoaded(x, y int) bool {
+	if w.loaded == nil {
+		return true
+	}
+
+	c := ChunkCoord{X: x / ChunkSize, Y: y / ChunkSize}
+
+	return w.ValidChunk(c) && w.loaded[c.Y][c.X]
+}
+
+// LoadProgress returns the amount of chunks which have
+// been loaded, and the total amount of chunks.
+func (w *World) LoadProgress() (loaded, total int) {
+	total = w.ChunksWide() * w.ChunksHigh()
+
+	if w.loaded == nil {
+		return total, total
+	}
+
+	for y := range w.loaded {
+		for x := range w.loaded[y] {
+			if w.loaded[y][x] {
+				loaded++
+			}
+		}
+	}
+
+	return loaded, total
+}
@@ -0,0 +1,106 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+func TestLoadChunk(t *testing.T) {
+	rows := []string{
+		"........#.......",
+		"........#.......",
+		"........#.......",
+		"........#.......",
+	}
+
+	var (
+		from = parseWorld(rows...)
+		to   = NewUnloaded(16, 4)
+		c    = ChunkCoord{X: 0, Y: 0}
+
+		left  = geom.Coord{X: 0, Y: 0}
+		right = geom.Coord{X: 15, Y: 3}
+	)
+
+	// Find a path and a flow field first, so there's
+	// something cached to throw away.
+	if _, ok := to.FindPath(left, right); !ok {
+		t.Fatal("expected a path before the chunk was loaded")
+	}
+
+	field := to.FlowField(right)
+
+	data, err := from.EncodeChunk(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := to.LoadChunk(c, data); err != nil {
+		t.Fatal(err)
+	}
+
+	checkTiles(t, to, rows...)
+
+	for y := range rows {
+		if node := to.Graph.At(8, y); node == nil || node.Tile != Land {
+			t.Errorf("expected the graph to have land at (8, %d)", y)
+		}
+	}
+
+	if !to.IsLoaded(0, 0) {
+		t.Error("expected the chunk to be loaded")
+	}
+
+	// The whole chunk only counts as one change.
+	if v := to.ChunkVersion(c); v != 1 {
+		t.Errorf("expected the chunk's version to be 1, got %d", v)
+	}
+
+	if to.Connected(left, right) {
+		t.Error("the regions weren't found again after the chunk was loaded")
+	}
+
+	if to.FlowField(right) == field {
+		t.Error("the flow field was kept after the chunk was loaded")
+	}
+
+	if path, ok := to.FindPath(left, right); ok {
+		t.Errorf("found a path through the wall: %v", path)
+	}
+
+	// Loading the same tiles again doesn't change anything.
+	if err := to.LoadChunk(c, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := to.ChunkVersion(c); v != 1 {
+		t.Errorf("expected the chunk's version to still be 1, got %d", v)
+	}
+}
+
+func TestLoadChunkBad(t *testing.T) {
+	w := NewUnloaded(16, 4)
+
+	tests := map[string][]byte{
+		"empty":        {},
+		"too short":    {10, 0},
+		"too long":     {65, 0},
+		"unknown tile": {64, 200, 1},
+		"cut off":      {64},
+	}
+
+	for name, data := range tests {
+		if err := w.LoadChunk(ChunkCoord{}, data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if err := w.LoadChunk(ChunkCoord{X: 1, Y: 0}, []byte{64, 0}); err == nil {
+		t.Error("expected an error for a chunk outside the world")
+	}
+
+	if w.IsLoaded(0, 0) || w.ChunkVersion(ChunkCoord{}) != 0 {
+		t.Error("a bad chunk was loaded")
+	}
+}
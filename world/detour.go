@@ -0,0 +1,105 @@
+package world
+
+import "github.com/Zac-Garby/pieces-of-seven/geom"
+
+// Some constants controlling the size of detours.
+const (
+	// detourLookahead is how many tiles ahead on a path
+	// are checked for obstacles. Ones further away will
+	// probably have moved by the time they're reached.
+	detourLookahead = 8
+
+	// detourRejoin is the amount of tiles past the last
+	// blocked one at which a detour rejoins its path.
+	detourRejoin = 3
+
+	// detourMargin is how far, in tiles, a detour can
+	// stray outside the part of the path it replaces.
+	detourMargin = 4
+)
+
+// FindDetour finds a way around the blocked tiles on a
+// path, such as ones with other ships in them. The first
+// coordinate of the path is where the search starts, and
+// the detour rejoins the path a few tiles after the last
+// blocked tile near the start. The boolean is false if
+// there's no way around, or the end of the path is
+// itself blocked.
+func (w *World) FindDetour(path []geom.Coord, blocked func(geom.Coord) bool) ([]geom.Coord, bool) {
+	if len(path) == 0 {
+		return path, false
+	}
+
+	last := -1
+
+	for i := 1; i < len(path) && i <= detourLookahead; i++ {
+		if blocked(path[i]) {
+			last = i
+		}
+	}
+
+	if last < 0 {
+		return path, true
+	}
+
+	if last == len(path)-1 {
+		return path, false
+	}
+
+	rejoin := last + detourRejoin
+	if rejoin >= len(path) {
+		rejoin = len(path) - 1
+	}
+
+	// The detour can only use the area around the part of
+	// the path it's replacing, so it stays short and quick
+	// to find.
+	area := bounds{x0: w.Width, y0: w.Height}
+
+	for _, pos := range path[:rejoin+1] {
+		x, y := int(pos.X), int(pos.Y)
+
+		if x-detourMargin < area.x0 {
+			area.x0 = x - detourMargin
+		}
+
+		if y-detourMargin < area.y0 {
+			area.y0 = y - detourMargin
+		}
+
+		if x+detourMargin+1 > area.x1 {
+			area.x1 = x + detourMargin + 1
+		}
+
+		if y+detourMargin+1 > area.y1 {
+			area.y1 = y + detourMargin + 1
+		}
+	}
+
+	if area.x0 < 0 {
+		area.x0 = 0
+	}
+
+	if area.y0 < 0 {
+		area.y0 = 0
+	}
+
+	if area.x1 > w.Width {
+		area.x1 = w.Width
+	}
+
+	if area.y1 > w.Height {
+		area.y1 = w.Height
+	}
+
+	target := path[rejoin]
+
+	search := w.Graph.search(area, path[0], &target, blocked)
+	if _, ok := search.cost(target); !ok {
+		return path, false
+	}
+
+	detour := append([]geom.Coord{path[0]}, search.pathTo(target)...)
+
+	return append(detour, path[rejoin+1:]...), true
+}
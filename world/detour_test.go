@@ -0,0 +1,116 @@
+package world
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// blockedAt returns a function for FindDetour which
+// blocks the given coordinates.
+func blockedAt(blocked ...geom.Coord) func(geom.Coord) bool {
+	return func(pos geom.Coord) bool {
+		for _, b := range blocked {
+			if pos == b {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+func TestFindDetour(t *testing.T) {
+	w := parseWorld(
+		"############",
+		"#...........",
+		"............",
+		"############",
+	)
+
+	var (
+		path    = coords(0, 2, 1, 2, 2, 2, 3, 2, 4, 2, 5, 2, 6, 2, 7, 2, 8, 2, 9, 2, 10, 2, 11, 2)
+		blocked = blockedAt(geom.Coord{X: 3, Y: 2}, geom.Coord{X: 4, Y: 2})
+	)
+
+	detour, ok := w.FindDetour(path, blocked)
+	if !ok {
+		t.Fatal("expected a detour")
+	}
+
+	checkPath(t, w, detour, path[0], path[len(path)-1])
+
+	for _, pos := range detour {
+		if blocked(pos) {
+			t.Errorf("the detour goes through %v, which is blocked", pos)
+		}
+	}
+
+	// The detour rejoins the path detourRejoin tiles after
+	// the last blocked one, at (7, 2), and follows it from
+	// there.
+	rejoined := len(detour) - len(path[7:])
+	if rejoined < 0 || !reflect.DeepEqual(detour[rejoined:], path[7:]) {
+		t.Fatalf("expected the detour to end with %v, got %v", path[7:], detour)
+	}
+
+	// Getting round takes two diagonal steps, up into the
+	// second row and back down again.
+	if cost, want := pathCost(t, w, detour[:rejoined+1]), 5+2*math.Sqrt2; math.Abs(cost-want) > costTolerance {
+		t.Errorf("expected the detour to (7, 2) to cost %v, got %v: %v", want, cost, detour)
+	}
+}
+
+func TestFindDetourUnchanged(t *testing.T) {
+	w := parseWorld(
+		"............",
+		"............",
+		"............",
+	)
+
+	path := coords(0, 1, 1, 1, 2, 1, 3, 1, 4, 1, 5, 1, 6, 1, 7, 1, 8, 1, 9, 1, 10, 1, 11, 1)
+
+	tests := []struct {
+		name    string
+		blocked []geom.Coord
+	}{
+		{"nothing blocked", nil},
+		{"the start blocked", coords(0, 1)},
+		{"blocked past the lookahead", coords(10, 1)},
+		{"blocked off the path", coords(3, 0, 3, 2)},
+	}
+
+	for _, test := range tests {
+		detour, ok := w.FindDetour(path, blockedAt(test.blocked...))
+
+		if !ok || !reflect.DeepEqual(detour, path) {
+			t.Errorf("%s: expected the path back unchanged, got %v (%v)", test.name, detour, ok)
+		}
+	}
+}
+
+func TestFindDetourImpossible(t *testing.T) {
+	w := parseWorld(
+		"##########",
+		"..........",
+		"##########",
+	)
+
+	tests := []struct {
+		name    string
+		path    []geom.Coord
+		blocked []geom.Coord
+	}{
+		{"no way round", coords(0, 1, 1, 1, 2, 1, 3, 1, 4, 1, 5, 1, 6, 1), coords(3, 1)},
+		{"the end blocked", coords(0, 1, 1, 1, 2, 1, 3, 1), coords(3, 1)},
+		{"an empty path", nil, nil},
+	}
+
+	for _, test := range tests {
+		if detour, ok := w.FindDetour(test.path, blockedAt(test.blocked...)); ok {
+			t.Errorf("%s: expected no detour, got %v", test.name, detour)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package world
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// MaxFlowFields is the amount of flow fields a World
+// keeps cached. When there are more, the least recently
+// made one is thrown away.
+const MaxFlowFields = 32
+
+// A FlowField leads every tile in the World towards a
+// single target. It's made with one search outwards
+// from the target, after which any number of ships can
+// look up their next step in constant time, instead of
+// each of them searching for its own path.
+//
+// A FlowField isn't updated when tiles change, so it
+// shouldn't be kept for long. World.FlowField always
+// returns an up to date one.
+type FlowField struct {
+	Target geom.Coord
+
+	width int
+	cost  []float64
+	next  []int
+}
+
+// FlowField returns a flow field leading to the given
+// target. Fields are cached, so heading lots of ships
+// to the same place only searches the World once. When
+// a tile changes, the cached fields are thrown away.
+func (w *World) FlowField(target geom.Coord) *FlowField {
+	if f, ok := w.flowFields[target]; ok {
+		return f
+	}
+
+	if w.flowFields == nil {
+		w.flowFields = make(map[geom.Coord]*FlowField)
+	}
+
+	if len(w.flowFields) >= MaxFlowFields {
+		oldest := w.flowOrder[0]
+		w.flowOrder = w.flowOrder[1:]
+
+		delete(w.flowFields, oldest)
+	}
+
+	f := w.makeFlowField(target)
+
+	w.flowFields[target] = f
+	w.flowOrder = append(w.flowOrder, target)
+
+	return f
+}
+
+// invalidateFlowFields throws away every cached field.
+// It's called whenever a tile changes.
+func (w *World) invalidateFlowFields() {
+	w.flowFields = nil
+	w.flowOrder = nil
+}
+
+// makeFlowField runs Dijkstra's algorithm outwards from
+// the target. Since moving between two tiles costs the
+// same both ways, each tile's next step is simply the
+// tile it was reached from.
+func (w *World) makeFlowField(target geom.Coord) *FlowField {
+	f := &FlowField{
+		Target: target,
+		width:  w.Width,
+		cost:   make([]float64, w.Width*w.Height),
+		next:   make([]int, w.Width*w.Height),
+	}
+
+	for i := range f.cost {
+		f.cost[i] = math.Inf(1)
+		f.next[i] = -1
+	}
+
+	start := w.Graph.AtCoord(target)
+	if start == nil || !start.Tile.GetData().Passable {
+		return f
+	}
+
+	var (
+		done  = make([]bool, len(f.cost))
+		queue = &coordQueue{}
+	)
+
+	f.cost[f.index(target)] = 0
+	heap.Push(queue, coordItem{pos: target})
+
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(coordItem)
+		i := f.index(item.pos)
+
+		if done[i] {
+			continue
+		}
+
+		done[i] = true
+
+		node := w.Graph.AtCoord(item.pos)
+
+		node.eachNeighbour(func(next *Node) {
+			var (
+				j = f.index(next.Pos)
+				c = f.cost[i] + node.PathNeighborCost(next)
+			)
+
+			if c < f.cost[j] {
+				f.cost[j] = c
+				f.next[j] = i
+
+				heap.Push(queue, coordItem{pos: next.Pos, priority: c})
+			}
+		})
+	}
+
+	return f
+}
+
+func (f *FlowField) index(pos geom.Coord) int {
+	return int(pos.Y)*f.width + int(pos.X)
+}
+
+func (f *FlowField) coord(i int) geom.Coord {
+	return geom.Coord{X: uint(i % f.width), Y: uint(i / f.width)}
+}
+
+func (f *FlowField) inBounds(pos geom.Coord) bool {
+	return int(pos.X) < f.width && f.index(pos) < len(f.cost)
+}
+
+// Next returns the next step from a tile towards the
+// target. The boolean is false if the target can't be
+// reached from the tile, or the tile is the target.
+func (f *FlowField) Next(from geom.Coord) (geom.Coord, bool) {
+	if !f.inBounds(from) {
+		return geom.Coord{}, false
+	}
+
+	next := f.next[f.index(from)]
+	if next < 0 {
+		return geom.Coord{}, false
+	}
+
+	return f.coord(next), true
+}
+
+// Cost returns the cost of the shortest path from a tile
+// to the target, and whether there is a path at all.
+func (f *FlowField) Cost(from geom.Coord) (float64, bool) {
+	if !f.inBounds(from) {
+		return 0, false
+	}
+
+	cost := f.cost[f.index(from)]
+
+	return cost, !math.IsInf(cost, 1)
+}
+
+// Path follows the field from a tile to the target,
+// returning the whole path in the same form as
+// World.FindPath.
+func (f *FlowField) Path(from geom.Coord) ([]geom.Coord, bool) {
+	if _, ok := f.Cost(from); !ok {
+		return []geom.Coord{}, false
+	}
+
+	path := []geom.Coord{from}
+
+	for pos, ok := f.Next(from); ok; pos, ok = f.Next(pos) {
+		path = append(path, pos)
+	}
+
+	return path, true
+}
@@ -0,0 +1,142 @@
+package world
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+func TestFlowFieldCost(t *testing.T) {
+	for _, w := range hpaWorlds(t, 96) {
+		var (
+			target = w.FindFreeSpace()
+			f      = w.FlowField(target)
+		)
+
+		for i := 0; i < 30; i++ {
+			from := w.FindFreeSpace()
+
+			cost, ok := f.Cost(from)
+			path, found := w.Graph.FindPath(from, target)
+
+			if ok != found {
+				t.Fatalf("from %v to %v: the field says %v, but A* says %v", from, target, ok, found)
+			}
+
+			if !ok {
+				continue
+			}
+
+			if want := pathCost(t, w, path); math.Abs(cost-want) > costTolerance {
+				t.Errorf("from %v to %v: the field costs %v, but A* costs %v", from, target, cost, want)
+			}
+
+			followed, ok := f.Path(from)
+			if !ok {
+				t.Fatalf("from %v to %v: there's a cost but no path", from, target)
+			}
+
+			checkPath(t, w, followed, from, target)
+
+			if got := pathCost(t, w, followed); math.Abs(got-cost) > costTolerance {
+				t.Errorf("from %v to %v: following the field costs %v, not %v", from, target, got, cost)
+			}
+		}
+	}
+}
+
+func TestFlowFieldUnreachable(t *testing.T) {
+	w := parseWorld(
+		"...#...",
+		"...#...",
+		"...#...",
+	)
+
+	var (
+		target = geom.Coord{X: 0, Y: 1}
+		f      = w.FlowField(target)
+	)
+
+	if cost, ok := f.Cost(target); !ok || cost != 0 {
+		t.Errorf("expected the target to cost 0, got %v (%v)", cost, ok)
+	}
+
+	if _, ok := f.Next(target); ok {
+		t.Error("expected no next step from the target")
+	}
+
+	if path, ok := f.Path(target); !ok || len(path) != 1 {
+		t.Errorf("expected a path of just the target, got %v (%v)", path, ok)
+	}
+
+	// The other side of the wall, the wall itself, and
+	// outside the world can't be reached.
+	for _, from := range coords(5, 1, 4, 0, 3, 1, 7, 0, 0, 3) {
+		if _, ok := f.Cost(from); ok {
+			t.Errorf("expected %v to be unreachable", from)
+		}
+
+		if _, ok := f.Next(from); ok {
+			t.Errorf("expected no next step from %v", from)
+		}
+
+		if path, ok := f.Path(from); ok || len(path) != 0 {
+			t.Errorf("expected no path from %v, got %v", from, path)
+		}
+	}
+
+	// Nothing can reach a target on land.
+	if _, ok := w.FlowField(geom.Coord{X: 3, Y: 0}).Cost(target); ok {
+		t.Error("expected a target on land to be unreachable")
+	}
+}
+
+func TestFlowFieldCache(t *testing.T) {
+	w := parseWorld(
+		"..........",
+		"..........",
+		"..........",
+		"..........",
+	)
+
+	target := geom.Coord{X: 9, Y: 3}
+	f := w.FlowField(target)
+
+	if w.FlowField(target) != f {
+		t.Error("the field wasn't cached")
+	}
+
+	w.SetTile(5, 0, Land)
+
+	if w.FlowField(target) == f {
+		t.Error("the field was kept after a tile changed")
+	}
+
+	if _, ok := w.FlowField(target).Cost(geom.Coord{X: 5, Y: 0}); ok {
+		t.Error("the new field can still reach the changed tile")
+	}
+
+	// The oldest fields are thrown away first, once there
+	// are more than MaxFlowFields.
+	var (
+		first  = w.FlowField(geom.Coord{X: 0, Y: 0})
+		fields []*FlowField
+	)
+
+	for i := 1; i <= MaxFlowFields; i++ {
+		fields = append(fields, w.FlowField(geom.Coord{X: uint(i % 10), Y: uint(i / 10)}))
+	}
+
+	if len(w.flowFields) != MaxFlowFields || len(w.flowOrder) != MaxFlowFields {
+		t.Errorf("expected %d fields cached, got %d", MaxFlowFields, len(w.flowFields))
+	}
+
+	if w.FlowField(geom.Coord{X: 2, Y: 3}) != fields[len(fields)-1] {
+		t.Error("the newest field was thrown away")
+	}
+
+	if w.FlowField(geom.Coord{X: 0, Y: 0}) == first {
+		t.Error("the oldest field was kept")
+	}
+}
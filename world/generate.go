@@ -1,67 +1,199 @@
 package world
 
 import (
+	"fmt"
 	"math/rand"
-	"time"
 )
 
-// Some constants related to world generation
+// genThreshold is the average value above which a
+// cell becomes land during smoothing.
+const genThreshold = 0.5
+
+// An Algorithm is a way of generating a World's
+// terrain.
+type Algorithm string
+
+// These are the available Algorithms.
 const (
-	genThreshold  = 0.5
-	genSampleDist = 3
-	genIterations = 5
+	// Cellular smooths random noise into islands of
+	// Land, surrounded by Water.
+	Cellular Algorithm = "cellular"
+
+	// Noise layers gradient noise into elevation and
+	// moisture maps, which decide each tile's biome.
+	Noise Algorithm = "noise"
 )
 
-type genData [Height][Width]int
+// A GeneratorConfig contains the parameters used to
+// generate a World.
+type GeneratorConfig struct {
+	// Algorithm is the way the terrain is generated.
+	// If it's empty, Cellular is used.
+	Algorithm Algorithm
+
+	// Width and Height are the size of the World,
+	// in Tiles.
+	Width, Height int
+
+	// LandRatio is the chance, from 0 to 1, of each
+	// cell starting out as land. The Noise algorithm
+	// uses it as the proportion of the world above
+	// sea level.
+	LandRatio float64
+
+	// SmoothingRadius is the radius, in Tiles, of the
+	// circle averaged over when smoothing. It's only
+	// used by the Cellular algorithm.
+	SmoothingRadius int
+
+	// Iterations is the amount of times the terrain
+	// is smoothed. It's only used by the Cellular
+	// algorithm.
+	Iterations int
+
+	// NoiseScale is roughly the size, in Tiles, of the
+	// largest features made by the Noise algorithm.
+	NoiseScale float64
+
+	// Octaves is the amount of layers of noise added
+	// together by the Noise algorithm. More octaves
+	// give rougher coastlines.
+	Octaves int
+}
+
+// DefaultGeneratorConfig is the config used when
+// none is given.
+var DefaultGeneratorConfig = GeneratorConfig{
+	Algorithm:       Cellular,
+	Width:           DefaultWidth,
+	Height:          DefaultHeight,
+	LandRatio:       0.5,
+	SmoothingRadius: 3,
+	Iterations:      5,
+	NoiseScale:      64,
+	Octaves:         5,
+}
+
+// Validate returns an error if the config can't be
+// used to generate a World.
+func (c GeneratorConfig) Validate() error {
+	if err := CheckSize(c.Width, c.Height); err != nil {
+		return err
+	}
+
+	if c.LandRatio < 0 || c.LandRatio > 1 {
+		return fmt.Errorf("invalid land ratio %v: must be between 0 and 1", c.LandRatio)
+	}
+
+	if c.SmoothingRadius < 0 {
+		return fmt.Errorf("invalid smoothing radius %d: must not be negative", c.SmoothingRadius)
+	}
+
+	if c.Iterations < 0 {
+		return fmt.Errorf("invalid iteration count %d: must not be negative", c.Iterations)
+	}
+
+	switch c.Algorithm {
+	case "", Cellular:
+
+	case Noise:
+		if c.NoiseScale <= 0 {
+			return fmt.Errorf("invalid noise scale %v: must be positive", c.NoiseScale)
+		}
+
+		if c.Octaves < 1 {
+			return fmt.Errorf("invalid octave count %d: must be at least 1", c.Octaves)
+		}
+
+	default:
+		return fmt.Errorf("unknown generator algorithm %q", c.Algorithm)
+	}
+
+	return nil
+}
+
+type genData [][]int
 type genCoord [2]int
 
-func init() {
-	// Randomly seed the rng
-	rand.Seed(int64(time.Now().Nanosecond()))
+func newGenData(width, height int) genData {
+	data := make(genData, height)
+
+	for y := range data {
+		data[y] = make([]int, width)
+	}
+
+	return data
 }
 
 // Generate creates a world with randomly generated
-// terrain. The way it works is first filling the
-// world with random ints, either 1 or 0. Then, it
-// will go through each cell and find the average
-// value of all cells in a certain radius. If that
-// average is above the threshold the cell is set
-// to 1, and otherwise it's set to 0. This is repeated
-// a number of times.
-func Generate() *World {
-	var data [Height][Width]int
+// terrain, using the config's Algorithm.
+//
+// The same seed and config always generate the same
+// world. An error is returned if the config is invalid.
+func Generate(seed int64, cfg GeneratorConfig) (*World, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		w   = blank(cfg.Width, cfg.Height)
+		rng = rand.New(rand.NewSource(seed))
+	)
+
+	w.Seed = seed
+
+	switch cfg.Algorithm {
+	case Noise:
+		generateNoise(w, rng, cfg)
+
+	default:
+		generateCellular(w, rng, cfg)
+	}
+
+	w.MakeGraph()
+
+	return w, nil
+}
+
+// generateCellular fills the world with islands of
+// Land. The way it works is first filling the world
+// with random ints, either 1 or 0. Then, it will go
+// through each cell and find the average value of all
+// cells in a certain radius. If that average is above
+// the threshold the cell is set to 1, and otherwise
+// it's set to 0. This is repeated a number of times.
+func generateCellular(w *World, rng *rand.Rand, cfg GeneratorConfig) {
+	data := newGenData(cfg.Width, cfg.Height)
 
 	// Random initial data
-	for y := 0; y < Height; y++ {
-		for x := 0; x < Width; x++ {
-			data[y][x] = rand.Int() % 2
+	for y := 0; y < cfg.Height; y++ {
+		for x := 0; x < cfg.Width; x++ {
+			if rng.Float64() < cfg.LandRatio {
+				data[y][x] = 1
+			}
 		}
 	}
 
 	// Iterate the data
-	for i := 0; i < genIterations; i++ {
-		data = iterate(data)
+	for i := 0; i < cfg.Iterations; i++ {
+		data = iterate(data, cfg)
 	}
 
-	w := New()
-
-	for y := 0; y < Height; y++ {
-		for x := 0; x < Width; x++ {
+	for y := 0; y < cfg.Height; y++ {
+		for x := 0; x < cfg.Width; x++ {
 			if data[y][x] > 0 {
 				w.Tiles[y][x] = Land
 			}
 		}
 	}
-
-	return w
 }
 
-func iterate(data genData) genData {
-	var newData genData
+func iterate(data genData, cfg GeneratorConfig) genData {
+	newData := newGenData(cfg.Width, cfg.Height)
 
-	for y := 0; y < Height; y++ {
-		for x := 0; x < Width; x++ {
-			coords := coordsInCircle(genCoord{x, y})
+	for y := 0; y < cfg.Height; y++ {
+		for x := 0; x < cfg.Width; x++ {
+			coords := coordsInCircle(genCoord{x, y}, cfg.SmoothingRadius)
 			total := 0.0
 
 			for _, coord := range coords {
@@ -70,7 +202,7 @@ func iterate(data genData) genData {
 					cy = coord[1]
 				)
 
-				if cx >= 0 && cy >= 0 && cx < Width && cy < Height {
+				if cx >= 0 && cy >= 0 && cx < cfg.Width && cy < cfg.Height {
 					total += float64(data[cy][cx])
 				} else {
 					// Prevent tiles being added around the edges of the map
@@ -91,18 +223,18 @@ func iterate(data genData) genData {
 	return newData
 }
 
-func coordsInCircle(origin genCoord) []genCoord {
+func coordsInCircle(origin genCoord, radius int) []genCoord {
 	var (
 		ox     = origin[0]
 		oy     = origin[1]
 		coords []genCoord
 	)
 
-	for x := ox - genSampleDist; x <= ox+genSampleDist; x++ {
-		for y := oy - genSampleDist; y <= oy+genSampleDist; y++ {
+	for x := ox - radius; x <= ox+radius; x++ {
+		for y := oy - radius; y <= oy+radius; y++ {
 			dist := (x-ox)*(x-ox) + (y-oy)*(y-oy)
 
-			if dist <= genSampleDist*genSampleDist {
+			if dist <= radius*radius {
 				coords = append(coords, genCoord{x, y})
 			}
 		}
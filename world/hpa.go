@@ -0,0 +1,526 @@
+package world
+
+import (
+	"container/heap"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// ClusterSize is the width and height, in Tiles, of the
+// clusters which a Hierarchy splits a Graph into.
+const ClusterSize = 16
+
+// maxEntranceWidth is the widest an entrance between two
+// clusters can be before it's given a transition at each
+// end, instead of a single one in the middle.
+const maxEntranceWidth = 6
+
+// A Hierarchy finds paths using hierarchical path-finding
+// (HPA*). The Graph is split into clusters, and the tiles
+// where ships can cross from one cluster to the next are
+// linked together into a much smaller abstract graph.
+// Long paths are found in the abstract graph first, then
+// refined one cluster at a time.
+//
+// The abstract graph is built lazily. When a tile changes,
+// Invalidate marks its cluster as dirty, and only the
+// dirty clusters and their neighbours are rebuilt before
+// the next path is found.
+type Hierarchy struct {
+	graph         *Graph
+	width, height int
+	wide, high    int
+
+	clusters []*cluster
+
+	// right holds the transitions between each cluster
+	// and the one to its right, and down holds the ones
+	// between each cluster and the one below it.
+	right, down [][]transition
+
+	dirty map[int]bool
+}
+
+// A transition is a pair of neighbouring tiles, in
+// neighbouring clusters, which a ship can cross between.
+type transition [2]geom.Coord
+
+// A cluster is a square area of the Graph. Its nodes are
+// the tiles in it which are part of a transition.
+type cluster struct {
+	bounds
+
+	nodes []geom.Coord
+
+	// edges links each node to the others in the cluster
+	// which it can reach without leaving it, and links
+	// links each node to its partners in other clusters.
+	edges map[geom.Coord][]abstractEdge
+	links map[geom.Coord][]abstractEdge
+}
+
+type abstractEdge struct {
+	to   geom.Coord
+	cost float64
+}
+
+// NewHierarchy creates a Hierarchy for the given Graph.
+// Every cluster starts off dirty, so nothing is computed
+// until the first path is found.
+func NewHierarchy(g *Graph) *Hierarchy {
+	h := &Hierarchy{
+		graph: g,
+		dirty: make(map[int]bool),
+	}
+
+	h.height = len(g.nodes)
+	if h.height > 0 {
+		h.width = len(g.nodes[0])
+	}
+
+	h.wide = (h.width + ClusterSize - 1) / ClusterSize
+	h.high = (h.height + ClusterSize - 1) / ClusterSize
+
+	h.clusters = make([]*cluster, h.wide*h.high)
+	h.right = make([][]transition, len(h.clusters))
+	h.down = make([][]transition, len(h.clusters))
+
+	for i := range h.clusters {
+		cx, cy := i%h.wide, i/h.wide
+
+		c := &cluster{
+			bounds: bounds{
+				x0: cx * ClusterSize,
+				y0: cy * ClusterSize,
+				x1: (cx + 1) * ClusterSize,
+				y1: (cy + 1) * ClusterSize,
+			},
+		}
+
+		if c.x1 > h.width {
+			c.x1 = h.width
+		}
+
+		if c.y1 > h.height {
+			c.y1 = h.height
+		}
+
+		h.clusters[i] = c
+		h.dirty[i] = true
+	}
+
+	return h
+}
+
+// Invalidate marks the cluster containing the tile at
+// (x, y) as changed, so it's rebuilt before the next
+// path is found.
+func (h *Hierarchy) Invalidate(x, y int) {
+	if x < 0 || y < 0 || x >= h.width || y >= h.height {
+		return
+	}
+
+	h.dirty[h.clusterIndex(geom.Coord{X: uint(x), Y: uint(y)})] = true
+}
+
+func (h *Hierarchy) clusterIndex(c geom.Coord) int {
+	return int(c.Y)/ClusterSize*h.wide + int(c.X)/ClusterSize
+}
+
+func (h *Hierarchy) clusterAt(c geom.Coord) *cluster {
+	return h.clusters[h.clusterIndex(c)]
+}
+
+// Update rebuilds the dirty clusters. The transitions
+// on each side of a dirty cluster are found again, and
+// since those are shared with its neighbours, they're
+// rebuilt too. It's called by FindPath, but can be called
+// beforehand so the first path isn't slow to find.
+func (h *Hierarchy) Update() {
+	if len(h.dirty) == 0 {
+		return
+	}
+
+	rebuild := make(map[int]bool)
+
+	for i := range h.dirty {
+		cx, cy := i%h.wide, i/h.wide
+
+		rebuild[i] = true
+
+		if cx+1 < h.wide {
+			h.right[i] = h.findTransitions(i, i+1)
+			rebuild[i+1] = true
+		}
+
+		if cy+1 < h.high {
+			h.down[i] = h.findTransitions(i, i+h.wide)
+			rebuild[i+h.wide] = true
+		}
+
+		if cx > 0 {
+			h.right[i-1] = h.findTransitions(i-1, i)
+			rebuild[i-1] = true
+		}
+
+		if cy > 0 {
+			h.down[i-h.wide] = h.findTransitions(i-h.wide, i)
+			rebuild[i-h.wide] = true
+		}
+	}
+
+	for i := range rebuild {
+		h.buildCluster(i)
+	}
+
+	h.dirty = make(map[int]bool)
+}
+
+// findTransitions finds the transitions between two
+// neighbouring clusters, a being left of or above b. The
+// border between them is split into entrances, which
+// are runs of tiles passable on both sides.
+func (h *Hierarchy) findTransitions(a, b int) []transition {
+	var (
+		ca, cb      = h.clusters[a], h.clusters[b]
+		transitions []transition
+		pairs       []transition
+	)
+
+	if b == a+1 {
+		for y := ca.y0; y < ca.y1; y++ {
+			pairs = append(pairs, transition{
+				{X: uint(ca.x1 - 1), Y: uint(y)},
+				{X: uint(cb.x0), Y: uint(y)},
+			})
+		}
+	} else {
+		for x := ca.x0; x < ca.x1; x++ {
+			pairs = append(pairs, transition{
+				{X: uint(x), Y: uint(ca.y1 - 1)},
+				{X: uint(x), Y: uint(cb.y0)},
+			})
+		}
+	}
+
+	var run []transition
+
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+
+		if len(run) < maxEntranceWidth {
+			transitions = append(transitions, run[len(run)/2])
+		} else {
+			transitions = append(transitions, run[0], run[len(run)-1])
+		}
+
+		run = nil
+	}
+
+	for _, pair := range pairs {
+		if h.passable(pair[0]) && h.passable(pair[1]) {
+			run = append(run, pair)
+		} else {
+			flush()
+		}
+	}
+
+	flush()
+
+	return transitions
+}
+
+func (h *Hierarchy) passable(c geom.Coord) bool {
+	return h.graph.passable(int(c.X), int(c.Y))
+}
+
+// buildCluster finds a cluster's nodes from the
+// transitions around it, then links them together with
+// the cost of the shortest path between them which stays
+// inside the cluster.
+func (h *Hierarchy) buildCluster(i int) {
+	var (
+		c      = h.clusters[i]
+		cx, cy = i % h.wide, i / h.wide
+	)
+
+	c.nodes = nil
+	c.edges = make(map[geom.Coord][]abstractEdge)
+	c.links = make(map[geom.Coord][]abstractEdge)
+
+	link := func(transitions []transition, side int) {
+		for _, t := range transitions {
+			from, to := t[side], t[1-side]
+
+			if _, ok := c.links[from]; !ok {
+				c.nodes = append(c.nodes, from)
+			}
+
+			c.links[from] = append(c.links[from], abstractEdge{
+				to:   to,
+				cost: h.graph.AtCoord(from).PathNeighborCost(h.graph.AtCoord(to)),
+			})
+		}
+	}
+
+	if cx+1 < h.wide {
+		link(h.right[i], 0)
+	}
+
+	if cy+1 < h.high {
+		link(h.down[i], 0)
+	}
+
+	if cx > 0 {
+		link(h.right[i-1], 1)
+	}
+
+	if cy > 0 {
+		link(h.down[i-h.wide], 1)
+	}
+
+	for _, node := range c.nodes {
+		search := h.graph.search(c.bounds, node, nil, nil)
+
+		for _, other := range c.nodes {
+			if d, ok := search.cost(other); ok && other != node {
+				c.edges[node] = append(c.edges[node], abstractEdge{to: other, cost: d})
+			}
+		}
+	}
+}
+
+// An abstractKey is a node in the abstract graph. The
+// goal is kept separate from the real nodes, since it's
+// only joined to the graph for a single search.
+type abstractKey struct {
+	pos  geom.Coord
+	goal bool
+}
+
+// FindPath finds a path from a coordinate to another,
+// returning a slice of coordinates and a boolean saying
+// whether a path exists or not, just like Graph.FindPath.
+// The path isn't always the shortest one, but it's
+// usually very close, and much quicker to find.
+func (h *Hierarchy) FindPath(from, to geom.Coord) ([]geom.Coord, bool) {
+	var (
+		start = h.graph.AtCoord(from)
+		end   = h.graph.AtCoord(to)
+	)
+
+	if start == nil || end == nil || !end.Tile.GetData().Passable {
+		return []geom.Coord{}, false
+	}
+
+	if from == to {
+		return []geom.Coord{from}, true
+	}
+
+	// Going through the abstract graph makes short paths
+	// take detours through the transitions, so paths
+	// between neighbouring clusters are found directly
+	// if they can be.
+	if area, ok := h.around(from, to); ok {
+		search := h.graph.search(area, from, &to, nil)
+
+		if _, ok := search.cost(to); ok {
+			return append([]geom.Coord{from}, search.pathTo(to)...), true
+		}
+	}
+
+	h.Update()
+
+	var (
+		startCluster = h.clusterAt(from)
+		goalCluster  = h.clusterAt(to)
+		goalKey      = abstractKey{pos: to, goal: true}
+
+		// The goal's distances are found by searching from
+		// the goal, which works because costs are the same
+		// in both directions.
+		startSearch = h.graph.search(startCluster.bounds, from, nil, nil)
+		goalSearch  = h.graph.search(goalCluster.bounds, to, nil, nil)
+
+		cost   = make(map[abstractKey]float64)
+		prev   = make(map[abstractKey]abstractKey)
+		closed = make(map[abstractKey]bool)
+		queue  = &abstractQueue{}
+	)
+
+	push := func(key, parent abstractKey, c float64, fromStart bool) {
+		if old, ok := cost[key]; ok && old <= c {
+			return
+		}
+
+		cost[key] = c
+
+		if fromStart {
+			delete(prev, key)
+		} else {
+			prev[key] = parent
+		}
+
+		heap.Push(queue, abstractItem{
+			key:      key,
+			priority: c + h.graph.AtCoord(key.pos).PathEstimatedCost(end),
+		})
+	}
+
+	// The start is joined to every node in its cluster it
+	// can reach, and to the goal if they share a cluster.
+	for _, node := range startCluster.nodes {
+		if d, ok := startSearch.cost(node); ok {
+			push(abstractKey{pos: node}, abstractKey{}, d, true)
+		}
+	}
+
+	if d, ok := startSearch.cost(to); ok {
+		push(goalKey, abstractKey{}, d, true)
+	}
+
+	found := false
+
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(abstractItem)
+		if closed[item.key] {
+			continue
+		}
+
+		closed[item.key] = true
+
+		if item.key == goalKey {
+			found = true
+			break
+		}
+
+		var (
+			pos = item.key.pos
+			c   = h.clusterAt(pos)
+			g   = cost[item.key]
+		)
+
+		for _, edge := range c.edges[pos] {
+			push(abstractKey{pos: edge.to}, item.key, g+edge.cost, false)
+		}
+
+		for _, edge := range c.links[pos] {
+			push(abstractKey{pos: edge.to}, item.key, g+edge.cost, false)
+		}
+
+		if c == goalCluster {
+			if d, ok := goalSearch.cost(pos); ok {
+				push(goalKey, item.key, g+d, false)
+			}
+		}
+	}
+
+	if !found {
+		return []geom.Coord{}, false
+	}
+
+	// Walk back from the goal to find the abstract path,
+	// then refine it into a real one.
+	waypoints := []geom.Coord{to}
+
+	for key := goalKey; ; {
+		parent, ok := prev[key]
+		if !ok {
+			break
+		}
+
+		waypoints = append(waypoints, parent.pos)
+		key = parent
+	}
+
+	waypoints = append(waypoints, from)
+
+	for i, j := 0, len(waypoints)-1; i < j; i, j = i+1, j-1 {
+		waypoints[i], waypoints[j] = waypoints[j], waypoints[i]
+	}
+
+	return h.refine(waypoints), true
+}
+
+// around returns an area covering both of the given
+// coordinates' clusters, if they're the same cluster or
+// neighbouring ones.
+func (h *Hierarchy) around(a, b geom.Coord) (bounds, bool) {
+	var (
+		ca = h.clusterAt(a)
+		cb = h.clusterAt(b)
+	)
+
+	if ca.x0-cb.x0 > ClusterSize || cb.x0-ca.x0 > ClusterSize ||
+		ca.y0-cb.y0 > ClusterSize || cb.y0-ca.y0 > ClusterSize {
+		return bounds{}, false
+	}
+
+	area := ca.bounds
+
+	if cb.x0 < area.x0 {
+		area.x0 = cb.x0
+	}
+
+	if cb.y0 < area.y0 {
+		area.y0 = cb.y0
+	}
+
+	if cb.x1 > area.x1 {
+		area.x1 = cb.x1
+	}
+
+	if cb.y1 > area.y1 {
+		area.y1 = cb.y1
+	}
+
+	return area, true
+}
+
+// refine turns a path through the abstract graph into
+// a path through the real one, by finding the path
+// between each pair of waypoints in the same cluster.
+func (h *Hierarchy) refine(waypoints []geom.Coord) []geom.Coord {
+	path := []geom.Coord{waypoints[0]}
+
+	for i := 1; i < len(waypoints); i++ {
+		var (
+			a = waypoints[i-1]
+			b = waypoints[i]
+			c = h.clusterAt(a)
+		)
+
+		if c != h.clusterAt(b) {
+			path = append(path, b)
+			continue
+		}
+
+		path = append(path, h.graph.search(c.bounds, a, &b, nil).pathTo(b)...)
+	}
+
+	return path
+}
+
+type abstractItem struct {
+	key      abstractKey
+	priority float64
+}
+
+// An abstractQueue is a priority queue of nodes in the
+// abstract graph, for use with container/heap.
+type abstractQueue []abstractItem
+
+func (q abstractQueue) Len() int            { return len(q) }
+func (q abstractQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q abstractQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *abstractQueue) Push(x interface{}) { *q = append(*q, x.(abstractItem)) }
+
+func (q *abstractQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return item
+}
@@ -0,0 +1,231 @@
+package world
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// Paths found by a Hierarchy have to pass through the
+// transitions between clusters, so they can't always be
+// the cheapest, but they should never be much worse. On
+// average, they can cost hpaMeanTolerance times as much as
+// the cheapest path, and at worst hpaWorstTolerance times
+// as much.
+const (
+	hpaMeanTolerance  = 1.1
+	hpaWorstTolerance = 1.5
+)
+
+// hpaWorlds generates some large worlds, with both of the
+// algorithms.
+func hpaWorlds(t testing.TB, size int) []*World {
+	var worlds []*World
+
+	for _, algorithm := range []Algorithm{Cellular, Noise} {
+		cfg := DefaultGeneratorConfig
+		cfg.Algorithm = algorithm
+		cfg.Width, cfg.Height = size, size
+
+		w, err := Generate(5, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		worlds = append(worlds, w)
+	}
+
+	return worlds
+}
+
+// checkPath fails the test if path doesn't go from one
+// coordinate to the other in steps a ship can take.
+func checkPath(t testing.TB, w *World, path []geom.Coord, from, to geom.Coord) {
+	if len(path) == 0 || path[0] != from || path[len(path)-1] != to {
+		t.Fatalf("path from %v to %v goes from %v to %v", from, to, path[0], path[len(path)-1])
+	}
+
+	for i := 1; i < len(path); i++ {
+		ok := false
+
+		w.Graph.AtCoord(path[i-1]).eachNeighbour(func(next *Node) {
+			ok = ok || next.Pos == path[i]
+		})
+
+		if !ok {
+			t.Fatalf("path from %v to %v steps from %v to %v", from, to, path[i-1], path[i])
+		}
+	}
+}
+
+func TestHierarchyFindPath(t *testing.T) {
+	for _, w := range hpaWorlds(t, 256) {
+		var (
+			worst = 1.0
+			total float64
+			paths int
+		)
+
+		for i := 0; i < 60; i++ {
+			from, to := w.FindFreeSpace(), w.FindFreeSpace()
+
+			flat, flatOK := w.Graph.FindPath(from, to)
+			hpa, hpaOK := w.Hierarchy.FindPath(from, to)
+
+			if flatOK != hpaOK {
+				t.Fatalf("%v to %v: A* found a path is %t, but HPA* is %t", from, to, flatOK, hpaOK)
+			}
+
+			if !flatOK {
+				continue
+			}
+
+			checkPath(t, w, hpa, from, to)
+
+			ratio := pathCost(t, w, hpa) / pathCost(t, w, flat)
+
+			if ratio < 1-costTolerance {
+				t.Errorf("%v to %v: HPA* path is cheaper than A*'s", from, to)
+			}
+
+			worst = math.Max(worst, ratio)
+			total += ratio
+			paths++
+		}
+
+		mean := total / float64(paths)
+
+		if mean > hpaMeanTolerance {
+			t.Errorf("HPA* paths cost %.3f times the cheapest on average, more than %.2f", mean, hpaMeanTolerance)
+		}
+
+		if worst > hpaWorstTolerance {
+			t.Errorf("worst HPA* path costs %.3f times the cheapest, more than %.2f", worst, hpaWorstTolerance)
+		}
+
+		t.Logf("HPA* paths cost %.3f times the cheapest on average, and %.3f at worst", mean, worst)
+	}
+}
+
+// changeTiles makes some random changes to a World, like
+// a changing map would.
+func changeTiles(w *World, changes int) {
+	w.FindFreeSpace()
+
+	for i := 0; i < changes; i++ {
+		x, y := w.rng.Intn(w.Width), w.rng.Intn(w.Height)
+
+		if i%2 == 0 {
+			w.SetTile(x, y, Rock)
+		} else {
+			w.SetTile(x, y, DeepWater)
+		}
+
+		// Sometimes the hierarchy is updated part of the
+		// way through, as it would be when ships move.
+		if i%50 == 0 {
+			w.Hierarchy.Update()
+		}
+	}
+}
+
+func TestHierarchyInvalidate(t *testing.T) {
+	for _, w := range hpaWorlds(t, 128) {
+		w.Hierarchy.Update()
+
+		changeTiles(w, 400)
+		w.Hierarchy.Update()
+
+		fresh := NewHierarchy(w.Graph)
+		fresh.Update()
+
+		if !reflect.DeepEqual(w.Hierarchy.right, fresh.right) || !reflect.DeepEqual(w.Hierarchy.down, fresh.down) {
+			t.Fatal("transitions differ from a full rebuild")
+		}
+
+		for i, c := range w.Hierarchy.clusters {
+			other := fresh.clusters[i]
+
+			if !reflect.DeepEqual(c.edges, other.edges) || !reflect.DeepEqual(c.links, other.links) {
+				t.Fatalf("cluster %d differs from a full rebuild", i)
+			}
+		}
+
+		for i := 0; i < 50; i++ {
+			from, to := w.FindFreeSpace(), w.FindFreeSpace()
+
+			got, gotOK := w.Hierarchy.FindPath(from, to)
+			want, wantOK := fresh.FindPath(from, to)
+
+			if gotOK != wantOK || !reflect.DeepEqual(got, want) {
+				t.Fatalf("%v to %v: path differs from a full rebuild's", from, to)
+			}
+		}
+	}
+}
+
+// benchPairs returns pairs of coordinates in a World which
+// are connected.
+func benchPairs(w *World, n int) [][2]geom.Coord {
+	var pairs [][2]geom.Coord
+
+	for len(pairs) < n {
+		from, to := w.FindFreeSpace(), w.FindFreeSpace()
+
+		if w.Connected(from, to) {
+			pairs = append(pairs, [2]geom.Coord{from, to})
+		}
+	}
+
+	return pairs
+}
+
+func benchWorld(b *testing.B) *World {
+	cfg := DefaultGeneratorConfig
+	cfg.Algorithm = Noise
+	cfg.Width, cfg.Height = 512, 512
+
+	w, err := Generate(5, cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return w
+}
+
+func BenchmarkGraphFindPath(b *testing.B) {
+	w := benchWorld(b)
+	pairs := benchPairs(w, 20)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		p := pairs[i%len(pairs)]
+		w.Graph.FindPath(p[0], p[1])
+	}
+}
+
+func BenchmarkHPA(b *testing.B) {
+	w := benchWorld(b)
+	pairs := benchPairs(w, 20)
+	w.Hierarchy.Update()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		p := pairs[i%len(pairs)]
+		w.Hierarchy.FindPath(p[0], p[1])
+	}
+}
+
+func BenchmarkHPABuild(b *testing.B) {
+	w := benchWorld(b)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		NewHierarchy(w.Graph).Update()
+	}
+}
@@ -0,0 +1,235 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A Palette maps each Tile to the colour it has in map
+// images. Imported images are matched against the
+// colours' red, green and blue channels, and alpha is
+// ignored, so each colour should only be used once.
+type Palette map[Tile]Colour
+
+// DefaultPalette returns a Palette containing the
+// Colour of every Tile.
+func DefaultPalette() Palette {
+	p := make(Palette, len(tileData))
+
+	for t, data := range tileData {
+		p[t] = data.Colour
+	}
+
+	return p
+}
+
+// ParsePalette reads a Palette from JSON, written as an
+// object from tile names to colours, such as:
+//
+//	{"water": "#2f6fb0", "land": "#c9b27a"}
+//
+// Tiles which aren't mentioned keep their usual colour.
+func ParsePalette(in io.Reader) (Palette, error) {
+	var colours map[string]string
+
+	if err := json.NewDecoder(in).Decode(&colours); err != nil {
+		return nil, fmt.Errorf("palette: %s", err)
+	}
+
+	p := DefaultPalette()
+
+	for name, hex := range colours {
+		t, ok := TileNamed(name)
+		if !ok {
+			return nil, fmt.Errorf("palette: unknown tile %q", name)
+		}
+
+		c, err := ParseColour(hex)
+		if err != nil {
+			return nil, fmt.Errorf("palette: %s", err)
+		}
+
+		p[t] = c
+	}
+
+	return p, p.check()
+}
+
+// LoadPalette reads a Palette from the file at path.
+func LoadPalette(path string) (Palette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return ParsePalette(f)
+}
+
+// ParseColour parses a colour written as #rrggbb, or
+// #rrggbbaa.
+func ParseColour(s string) (Colour, error) {
+	c := Colour{0, 0, 0, 255}
+	hex := strings.TrimPrefix(s, "#")
+
+	if len(hex) != 6 && len(hex) != 8 {
+		return c, fmt.Errorf("invalid colour %q: expected #rrggbb", s)
+	}
+
+	for i := 0; i < len(hex)/2; i++ {
+		v, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return c, fmt.Errorf("invalid colour %q: expected #rrggbb", s)
+		}
+
+		c[i] = uint8(v)
+	}
+
+	return c, nil
+}
+
+// String returns the colour as #rrggbb.
+func (c Colour) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+}
+
+func (c Colour) rgb() [3]uint8 {
+	return [3]uint8{c[0], c[1], c[2]}
+}
+
+// check returns an error if two Tiles have the same
+// colour, since an image using it would be ambiguous.
+func (p Palette) check() error {
+	seen := make(map[[3]uint8]Tile, len(p))
+
+	for t, c := range p {
+		if other, ok := seen[c.rgb()]; ok {
+			return fmt.Errorf("palette: %s and %s are both %s", t.GetData().Name, other.GetData().Name, c)
+		}
+
+		seen[c.rgb()] = t
+	}
+
+	return nil
+}
+
+// FromImage makes a World from a map image, with one
+// pixel per Tile. Every pixel must be one of the
+// Palette's colours.
+func FromImage(img image.Image, p Palette) (*World, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+
+	var (
+		bounds = img.Bounds()
+		tiles  = make(map[[3]uint8]Tile, len(p))
+	)
+
+	if err := CheckSize(bounds.Dx(), bounds.Dy()); err != nil {
+		return nil, err
+	}
+
+	for t, c := range p {
+		tiles[c.rgb()] = t
+	}
+
+	w := blank(bounds.Dx(), bounds.Dy())
+
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			rgb := [3]uint8{c.R, c.G, c.B}
+
+			t, ok := tiles[rgb]
+			if !ok {
+				return nil, fmt.Errorf("unknown colour %s at (%d, %d)", Colour{c.R, c.G, c.B, c.A}, x, y)
+			}
+
+			w.Tiles[y][x] = t
+		}
+	}
+
+	w.MakeGraph()
+
+	return w, nil
+}
+
+// ImportImage reads a World from a PNG map image.
+func ImportImage(in io.Reader, p Palette) (*World, error) {
+	img, err := png.Decode(in)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromImage(img, p)
+}
+
+// ImportFile imports a World from a hand-made map. It
+// can be a PNG map image, using the given Palette, or a
+// Tiled map, as for LoadTiled.
+func ImportFile(path string, p Palette) (*World, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".png":
+		return importPNG(path, p)
+
+	case ".tmx", ".json", ".tmj":
+		return LoadTiled(path)
+
+	default:
+		return nil, fmt.Errorf("%s: unsupported map format %q: expected .png, .tmx, .json or .tmj", path, ext)
+	}
+}
+
+// importPNG imports a World from a PNG map image.
+func importPNG(path string, p Palette) (*World, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	w, err := ImportImage(f, p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return w, nil
+}
+
+// Image draws an overview of the World, with each Tile
+// as a square of scale by scale pixels in its colour
+// from the Palette.
+func (w *World) Image(p Palette, scale int) *image.NRGBA {
+	if scale < 1 {
+		scale = 1
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w.Width*scale, w.Height*scale))
+
+	for y := 0; y < w.Height*scale; y++ {
+		for x := 0; x < w.Width*scale; x++ {
+			c := p[w.Tiles[y/scale][x/scale]]
+
+			img.SetNRGBA(x, y, color.NRGBA{R: c[0], G: c[1], B: c[2], A: c[3]})
+		}
+	}
+
+	return img
+}
+
+// ExportImage writes an overview of the World to out as
+// a PNG. See Image.
+func (w *World) ExportImage(out io.Writer, p Palette, scale int) error {
+	return png.Encode(out, w.Image(p, scale))
+}
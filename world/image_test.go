@@ -0,0 +1,57 @@
+package world
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportFile(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+
+	w := parseWorld(
+		"..##.",
+		".d#s.",
+		"r...#",
+	)
+
+	path := filepath.Join(dir, "map.PNG")
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = png.Encode(f, w.Image(DefaultPalette(), 1))
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := ImportFile(path, DefaultPalette())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hashTiles(imported) != hashTiles(w) {
+		t.Error("the imported tiles don't match the image")
+	}
+
+	if imported.Graph == nil || imported.Hierarchy == nil {
+		t.Error("the imported world has no path-finding graph")
+	}
+}
+
+func TestImportFileFormat(t *testing.T) {
+	for _, path := range []string{"map.bmp", "map.world", "map"} {
+		_, err := ImportFile(path, DefaultPalette())
+
+		if err == nil || !strings.Contains(err.Error(), "unsupported map format") {
+			t.Errorf("%s: expected an unsupported map format error, got %v", path, err)
+		}
+	}
+}
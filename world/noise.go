@@ -0,0 +1,93 @@
+package world
+
+import (
+	"math"
+	"math/rand"
+)
+
+// gradients are the directions which the noise's
+// gradient vectors are chosen from.
+var gradients = [8][2]float64{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{math.Sqrt2 / 2, math.Sqrt2 / 2}, {-math.Sqrt2 / 2, math.Sqrt2 / 2},
+	{math.Sqrt2 / 2, -math.Sqrt2 / 2}, {-math.Sqrt2 / 2, -math.Sqrt2 / 2},
+}
+
+// noise generates 2d gradient noise, also known as
+// Perlin noise. Its permutation table is shuffled by
+// a random number generator, so the same generator
+// state always gives the same noise.
+type noise struct {
+	perm [512]int
+}
+
+func newNoise(rng *rand.Rand) *noise {
+	n := &noise{}
+
+	for i, p := range rng.Perm(256) {
+		n.perm[i] = p
+		n.perm[i+256] = p
+	}
+
+	return n
+}
+
+// at returns the noise at (x, y), which is roughly
+// between -1 and 1.
+func (n *noise) at(x, y float64) float64 {
+	var (
+		x0 = math.Floor(x)
+		y0 = math.Floor(y)
+		xi = int(x0) & 255
+		yi = int(y0) & 255
+		xf = x - x0
+		yf = y - y0
+	)
+
+	dot := func(cx, cy int, dx, dy float64) float64 {
+		g := gradients[n.perm[n.perm[cx]+cy]%len(gradients)]
+		return g[0]*dx + g[1]*dy
+	}
+
+	var (
+		n00 = dot(xi, yi, xf, yf)
+		n10 = dot(xi+1, yi, xf-1, yf)
+		n01 = dot(xi, yi+1, xf, yf-1)
+		n11 = dot(xi+1, yi+1, xf-1, yf-1)
+		u   = fade(xf)
+		v   = fade(yf)
+	)
+
+	return math.Sqrt2 * lerp(lerp(n00, n10, u), lerp(n01, n11, u), v)
+}
+
+// fractal adds together a number of octaves of noise,
+// each at double the frequency and half the amplitude
+// of the last, then scales the result to be between
+// 0 and 1.
+func (n *noise) fractal(x, y float64, octaves int) float64 {
+	var (
+		total     = 0.0
+		amplitude = 1.0
+		frequency = 1.0
+		max       = 0.0
+	)
+
+	for i := 0; i < octaves; i++ {
+		total += n.at(x*frequency, y*frequency) * amplitude
+		max += amplitude
+
+		amplitude /= 2
+		frequency *= 2
+	}
+
+	return (total/max + 1) / 2
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
@@ -1,21 +1,25 @@
 package world
 
 import (
+	"math"
+
 	"github.com/Zac-Garby/pieces-of-seven/geom"
 	"github.com/beefsack/go-astar"
 )
 
 // A Graph is a graph representation of a World,
 // for use in pathfinding.
-type Graph [Height][Width]*Node
+type Graph struct {
+	nodes [][]*Node
+}
 
 // At returns the node located at (x, y)
 func (g *Graph) At(x, y int) *Node {
-	if x < 0 || x >= Width || y < 0 || y >= Height {
+	if y < 0 || y >= len(g.nodes) || x < 0 || x >= len(g.nodes[y]) {
 		return nil
 	}
 
-	return g[y][x]
+	return g.nodes[y][x]
 }
 
 // AtCoord returns the node located at the given coordinate
@@ -58,49 +62,88 @@ type Node struct {
 	Graph *Graph
 }
 
-// PathNeighbors returns the immediate neighbours
-// of a Node.
-func (n *Node) PathNeighbors() []astar.Pather {
-	var (
-		x          = int(n.Pos.X)
-		y          = int(n.Pos.Y)
-		neighbours = []astar.Pather{}
-	)
+// neighbourOffsets are the offsets, as {dx, dy}, of the
+// eight tiles around a Node.
+var neighbourOffsets = [][2]int{
+	{0, -1},
+	{0, +1},
+	{-1, 0},
+	{+1, 0},
+	{-1, -1},
+	{+1, -1},
+	{-1, +1},
+	{+1, +1},
+}
 
-	for _, offset := range [][]int{
-		{0, -1},
-		{0, +1},
-		{-1, 0},
-		{+1, 0},
-	} {
-		if node := n.Graph.At(x+offset[1], y+offset[0]); node != nil && node.Tile.GetData().Passable {
-			neighbours = append(neighbours, node)
+// passable checks whether there's a passable Node at
+// (x, y).
+func (g *Graph) passable(x, y int) bool {
+	node := g.At(x, y)
+	return node != nil && node.Tile.GetData().Passable
+}
+
+// eachNeighbour calls fn with each of the immediate
+// neighbours of a Node, including diagonal ones. A
+// diagonal neighbour is only included if both of the
+// tiles next to it are passable too, so paths can't cut
+// across corners.
+func (n *Node) eachNeighbour(fn func(next *Node)) {
+	x, y := int(n.Pos.X), int(n.Pos.Y)
+
+	for _, offset := range neighbourOffsets {
+		dx, dy := offset[0], offset[1]
+
+		if !n.Graph.passable(x+dx, y+dy) {
+			continue
 		}
+
+		if dx != 0 && dy != 0 && (!n.Graph.passable(x+dx, y) || !n.Graph.passable(x, y+dy)) {
+			continue
+		}
+
+		fn(n.Graph.nodes[y+dy][x+dx])
 	}
+}
+
+// PathNeighbors returns the immediate neighbours of a
+// Node which a path can move to.
+func (n *Node) PathNeighbors() []astar.Pather {
+	neighbours := []astar.Pather{}
+
+	n.eachNeighbour(func(next *Node) {
+		neighbours = append(neighbours, next)
+	})
 
 	return neighbours
 }
 
 // PathNeighborCost returns the cost to move from one
-// node to another. At the moment, the cost is always 1.0.
+// node to a neighbouring one, which is the average of
+// both tiles' costs, multiplied by the distance between
+// them. Since it's the same in both directions, it
+// doesn't matter that paths are searched for backwards.
 func (n *Node) PathNeighborCost(to astar.Pather) float64 {
-	return 1.0
-}
-
-// PathEstimatedCost returns the manhattan distance
-// between 2 nodes, |x2 - x1| + |y2 - y1|
-func (n *Node) PathEstimatedCost(to astar.Pather) float64 {
 	other := to.(*Node)
+	cost := (n.Tile.GetData().Cost + other.Tile.GetData().Cost) / 2
 
-	absX := int(other.Pos.X - n.Pos.X)
-	if absX < 0 {
-		absX = -absX
+	if n.Pos.X != other.Pos.X && n.Pos.Y != other.Pos.Y {
+		return cost * math.Sqrt2
 	}
 
-	absY := int(other.Pos.Y - n.Pos.Y)
-	if absY < 0 {
-		absY = -absY
-	}
+	return cost
+}
+
+// PathEstimatedCost returns the octile distance between
+// 2 nodes, which is the length of the shortest path
+// between them made of straight and diagonal steps. It's
+// multiplied by the cheapest possible cost of a step so
+// it's never more than the real cost.
+func (n *Node) PathEstimatedCost(to astar.Pather) float64 {
+	var (
+		other = to.(*Node)
+		dx    = math.Abs(float64(other.Pos.X) - float64(n.Pos.X))
+		dy    = math.Abs(float64(other.Pos.Y) - float64(n.Pos.Y))
+	)
 
-	return float64(absX + absY)
+	return (math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)) * minCost
 }
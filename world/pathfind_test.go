@@ -0,0 +1,274 @@
+package world
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// costTolerance is how far apart two costs can be while
+// still being the same, allowing for rounding.
+const costTolerance = 1e-9
+
+// pathCost returns the cost of following a path, failing
+// the test if it ever jumps more than one tile.
+func pathCost(t testing.TB, w *World, path []geom.Coord) float64 {
+	var total float64
+
+	for i := 1; i < len(path); i++ {
+		dx := int(path[i].X) - int(path[i-1].X)
+		dy := int(path[i].Y) - int(path[i-1].Y)
+
+		if dx*dx > 1 || dy*dy > 1 {
+			t.Fatalf("path jumps from %v to %v", path[i-1], path[i])
+		}
+
+		total += w.Graph.AtCoord(path[i-1]).PathNeighborCost(w.Graph.AtCoord(path[i]))
+	}
+
+	return total
+}
+
+// cheapestCost returns the cost of the cheapest path
+// between two tiles, found by searching the whole World
+// with Dijkstra's algorithm.
+func cheapestCost(w *World, from, to geom.Coord) (float64, bool) {
+	s := w.Graph.search(bounds{0, 0, w.Width, w.Height}, from, &to, nil)
+
+	return s.cost(to)
+}
+
+func coords(xys ...uint) []geom.Coord {
+	var cs []geom.Coord
+
+	for i := 0; i < len(xys); i += 2 {
+		cs = append(cs, geom.Coord{X: xys[i], Y: xys[i+1]})
+	}
+
+	return cs
+}
+
+func TestFindPathCrafted(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []string
+		path []geom.Coord
+		cost float64
+	}{
+		{
+			name: "straight",
+			rows: []string{
+				".....",
+			},
+			path: coords(0, 0, 1, 0, 2, 0, 3, 0, 4, 0),
+			cost: 4,
+		},
+		{
+			name: "diagonal",
+			rows: []string{
+				"....",
+				"....",
+				"....",
+				"....",
+			},
+			path: coords(0, 0, 1, 1, 2, 2, 3, 3),
+			cost: 3 * math.Sqrt2,
+		},
+		{
+			name: "around a reef",
+			rows: []string{
+				".....",
+				".rrr.",
+				".rrr.",
+				"sssss",
+			},
+			path: coords(0, 1, 1, 0, 2, 0, 3, 0, 4, 1),
+			cost: 2 + 2*math.Sqrt2,
+		},
+		{
+			name: "through a reef rather than a long way round",
+			rows: []string{
+				"...#...",
+				"...r...",
+				"...#...",
+				"...#...",
+				"...#...",
+				".......",
+			},
+			path: coords(0, 1, 1, 1, 2, 1, 3, 1, 4, 1, 5, 1, 6, 1),
+			cost: 1 + 1 + 2.5 + 2.5 + 1 + 1,
+		},
+		{
+			name: "through the only gap",
+			rows: []string{
+				"..#..",
+				"..#..",
+				"..r..",
+				"..#..",
+				"..#..",
+			},
+			path: coords(0, 2, 1, 2, 2, 2, 3, 2, 4, 2),
+			cost: 1 + 2.5 + 2.5 + 1,
+		},
+		{
+			name: "off the shallows",
+			rows: []string{
+				"sssss",
+				".....",
+			},
+			path: coords(0, 0, 1, 1, 2, 1, 3, 1, 4, 0),
+			cost: 2 + 2*1.25*math.Sqrt2,
+		},
+		{
+			name: "not across corners",
+			rows: []string{
+				"...",
+				".#.",
+				"...",
+			},
+			path: coords(0, 1, 0, 0, 1, 0),
+			cost: 2,
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			w        = parseWorld(test.rows...)
+			from, to = test.path[0], test.path[len(test.path)-1]
+		)
+
+		path, ok := w.Graph.FindPath(from, to)
+		if !ok {
+			t.Errorf("%s: no path found", test.name)
+			continue
+		}
+
+		if !reflect.DeepEqual(path, test.path) {
+			t.Errorf("%s: got path %v, want %v", test.name, path, test.path)
+		}
+
+		if cost := pathCost(t, w, path); math.Abs(cost-test.cost) > costTolerance {
+			t.Errorf("%s: got cost %f, want %f", test.name, cost, test.cost)
+		}
+	}
+}
+
+func TestFindPathBlocked(t *testing.T) {
+	w := parseWorld(
+		".#.",
+		"#..",
+	)
+
+	// The only way would be between two corners.
+	if path, ok := w.Graph.FindPath(geom.Coord{X: 0, Y: 0}, geom.Coord{X: 1, Y: 1}); ok {
+		t.Errorf("found path %v", path)
+	}
+
+	// Land can't be sailed to.
+	if path, ok := w.Graph.FindPath(geom.Coord{X: 2, Y: 0}, geom.Coord{X: 1, Y: 0}); ok {
+		t.Errorf("found path %v", path)
+	}
+}
+
+func TestPathNeighborCost(t *testing.T) {
+	w := parseWorld(
+		".r",
+		"sd",
+	)
+
+	at := func(x, y int) *Node {
+		return w.Graph.At(x, y)
+	}
+
+	tests := []struct {
+		from, to *Node
+		cost     float64
+	}{
+		{at(0, 0), at(1, 0), (1 + 4) / 2.0},
+		{at(1, 0), at(0, 0), (1 + 4) / 2.0},
+		{at(0, 0), at(0, 1), (1 + 1.5) / 2.0},
+		{at(0, 0), at(1, 1), math.Sqrt2},
+		{at(1, 0), at(0, 1), (4 + 1.5) / 2.0 * math.Sqrt2},
+	}
+
+	for _, test := range tests {
+		if cost := test.from.PathNeighborCost(test.to); math.Abs(cost-test.cost) > costTolerance {
+			t.Errorf("%v to %v: got %f, want %f", test.from.Pos, test.to.Pos, cost, test.cost)
+		}
+	}
+}
+
+func TestPathEstimatedCost(t *testing.T) {
+	w := New(10, 10)
+
+	tests := []struct {
+		dx, dy int
+		cost   float64
+	}{
+		{0, 0, 0},
+		{5, 0, 5},
+		{0, 7, 7},
+		{3, 3, 3 * math.Sqrt2},
+		{5, 2, 3 + 2*math.Sqrt2},
+		{1, 6, 5 + math.Sqrt2},
+	}
+
+	for _, test := range tests {
+		var (
+			from = w.Graph.At(1, 1)
+			to   = w.Graph.At(1+test.dx, 1+test.dy)
+		)
+
+		if cost := from.PathEstimatedCost(to); math.Abs(cost-test.cost) > costTolerance {
+			t.Errorf("(%d, %d): got %f, want %f", test.dx, test.dy, cost, test.cost)
+		}
+
+		if cost := to.PathEstimatedCost(from); math.Abs(cost-test.cost) > costTolerance {
+			t.Errorf("(%d, %d) backwards: got %f, want %f", test.dx, test.dy, cost, test.cost)
+		}
+	}
+}
+
+// TestFindPathOptimal checks that paths through a world
+// with every kind of water are as cheap as possible, and
+// that the estimates never overshoot.
+func TestFindPathOptimal(t *testing.T) {
+	cfg := GeneratorConfig{
+		Algorithm:  Noise,
+		Width:      48,
+		Height:     48,
+		LandRatio:  0.4,
+		NoiseScale: 12,
+		Octaves:    4,
+	}
+
+	w, err := Generate(11, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 30; i++ {
+		from, to := w.FindFreeSpace(), w.FindFreeSpace()
+
+		want, reachable := cheapestCost(w, from, to)
+
+		path, ok := w.Graph.FindPath(from, to)
+		if ok != reachable {
+			t.Fatalf("%v to %v: found a path is %t, but one exists is %t", from, to, ok, reachable)
+		}
+
+		if !ok {
+			continue
+		}
+
+		if cost := pathCost(t, w, path); math.Abs(cost-want) > costTolerance {
+			t.Errorf("%v to %v: got cost %f, want %f", from, to, cost, want)
+		}
+
+		if estimate := w.Graph.AtCoord(from).PathEstimatedCost(w.Graph.AtCoord(to)); estimate > want+costTolerance {
+			t.Errorf("%v to %v: estimated %f, but it costs %f", from, to, estimate, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package world
+
+import "github.com/Zac-Garby/pieces-of-seven/geom"
+
+// FindNearestPath finds a path from a coordinate to
+// another, like FindPath. If the destination is
+// impassable or can't be reached, such as when it's on
+// an island, the path goes to the nearest tile to it
+// which can be reached instead. The boolean is false
+// if there's nowhere to go at all.
+func (w *World) FindNearestPath(from, to geom.Coord) ([]geom.Coord, bool) {
+	if path, ok := w.FindPath(from, to); ok {
+		return path, true
+	}
+
+	nearest, ok := w.NearestReachable(from, to)
+	if !ok {
+		return []geom.Coord{}, false
+	}
+
+	return w.FindPath(from, nearest)
+}
+
+// NearestReachable returns the passable tile nearest to
+// target which can be reached from the given coordinate.
+// The boolean is false if nothing can be reached. A
+// target outside the World is moved to its nearest edge
+// first.
+func (w *World) NearestReachable(from, target geom.Coord) (geom.Coord, bool) {
+	region := w.Region(int(from.X), int(from.Y))
+	if region == NoRegion {
+		return geom.Coord{}, false
+	}
+
+	var (
+		tx       = clamp(int(target.X), 0, w.Width-1)
+		ty       = clamp(int(target.Y), 0, w.Height-1)
+		best     geom.Coord
+		bestDist = -1
+	)
+
+	check := func(x, y int) {
+		if w.Region(x, y) != region {
+			return
+		}
+
+		dist := (x-tx)*(x-tx) + (y-ty)*(y-ty)
+
+		if bestDist < 0 || dist < bestDist {
+			best = geom.Coord{X: uint(x), Y: uint(y)}
+			bestDist = dist
+		}
+	}
+
+	// Tiles are checked in square rings around the target,
+	// getting bigger each time. A tile in ring r is at
+	// least r tiles away, so once a ring is further away
+	// than the best tile found so far, there's no point
+	// going any further.
+	for r := 0; r < w.Width+w.Height; r++ {
+		if bestDist >= 0 && r*r > bestDist {
+			break
+		}
+
+		for x := tx - r; x <= tx+r; x++ {
+			check(x, ty-r)
+			check(x, ty+r)
+		}
+
+		for y := ty - r + 1; y < ty+r; y++ {
+			check(tx-r, y)
+			check(tx+r, y)
+		}
+	}
+
+	return best, bestDist >= 0
+}
@@ -0,0 +1,74 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+func TestNearestReachable(t *testing.T) {
+	w := parseWorld(
+		"........",
+		"..###...",
+		"..###...",
+		"..###...",
+		"........",
+		"###.....",
+		".#......",
+		"##......",
+	)
+
+	tests := []struct {
+		target geom.Coord
+		want   geom.Coord
+	}{
+		// Water is reachable itself.
+		{geom.Coord{X: 6, Y: 6}, geom.Coord{X: 6, Y: 6}},
+
+		// The middle of the island is two tiles from the
+		// water on each side, so the nearest found is the
+		// first of those.
+		{geom.Coord{X: 3, Y: 2}, geom.Coord{X: 3, Y: 0}},
+
+		// The lagoon is closed off, so the nearest water
+		// which can be reached is outside it.
+		{geom.Coord{X: 0, Y: 6}, geom.Coord{X: 0, Y: 4}},
+	}
+
+	for _, test := range tests {
+		got, ok := w.NearestReachable(geom.Coord{X: 7, Y: 0}, test.target)
+		if !ok {
+			t.Errorf("%v: nothing reachable", test.target)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("%v: got %v, want %v", test.target, got, test.want)
+		}
+	}
+}
+
+func TestNearestReachableOutside(t *testing.T) {
+	w := parseWorld(
+		"....",
+		".##.",
+		".##.",
+		"...#",
+	)
+
+	tests := []struct {
+		target geom.Coord
+		want   geom.Coord
+	}{
+		{geom.Coord{X: 100, Y: 0}, geom.Coord{X: 3, Y: 0}},
+		{geom.Coord{X: 1 << 40, Y: 1 << 40}, geom.Coord{X: 3, Y: 2}},
+		{geom.Coord{X: ^uint(0), Y: 2}, geom.Coord{X: 0, Y: 2}},
+	}
+
+	for _, test := range tests {
+		got, ok := w.NearestReachable(geom.Coord{X: 0, Y: 0}, test.target)
+		if !ok || got != test.want {
+			t.Errorf("%v: got %v (%t), want %v", test.target, got, ok, test.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package world
+
+import (
+	"fmt"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// NoRegion is the region of impassable tiles.
+const NoRegion = 0
+
+// A Zone is a rectangular area of a World, in Tiles.
+type Zone struct {
+	X, Y          int
+	Width, Height int
+}
+
+// Contains checks whether (x, y) is inside the Zone.
+func (z Zone) Contains(x, y int) bool {
+	return x >= z.X && y >= z.Y && x < z.X+z.Width && y < z.Y+z.Height
+}
+
+// A Port is a named harbour in a World.
+type Port struct {
+	Name string
+	Pos  geom.Coord
+}
+
+// An Area is a named Zone of a World, such as one drawn
+// in a map editor.
+type Area struct {
+	Name string
+	Zone
+}
+
+// ParseZone parses a Zone written as "x,y,width,height".
+func ParseZone(s string) (Zone, error) {
+	var z Zone
+
+	if _, err := fmt.Sscanf(s, "%d,%d,%d,%d", &z.X, &z.Y, &z.Width, &z.Height); err != nil {
+		return Zone{}, fmt.Errorf("invalid zone %q: expected x,y,width,height", s)
+	}
+
+	if z.Width <= 0 || z.Height <= 0 {
+		return Zone{}, fmt.Errorf("invalid zone %q: width and height must be positive", s)
+	}
+
+	return z, nil
+}
+
+// regions labels each connected body of water in a
+// World, so it's quick to tell whether one tile can be
+// reached from another.
+type regions struct {
+	// labels holds the region of each tile, indexed by
+	// y*Width + x, or NoRegion if it's impassable.
+	labels []int
+
+	// sizes holds the amount of tiles in each region,
+	// indexed by region.
+	sizes []int
+
+	largest int
+}
+
+// labelRegions flood fills the World, giving each set of
+// connected passable tiles its own region, numbered
+// from 1.
+func (w *World) labelRegions() *regions {
+	r := &regions{
+		labels: make([]int, w.Width*w.Height),
+		sizes:  []int{0},
+	}
+
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if r.labels[y*w.Width+x] != NoRegion || !w.Tiles[y][x].GetData().Passable {
+				continue
+			}
+
+			var (
+				id    = len(r.sizes)
+				size  = 1
+				queue = []*Node{w.Graph.At(x, y)}
+			)
+
+			r.labels[y*w.Width+x] = id
+
+			for len(queue) > 0 {
+				node := queue[0]
+				queue = queue[1:]
+
+				node.eachNeighbour(func(next *Node) {
+					i := int(next.Pos.Y)*w.Width + int(next.Pos.X)
+
+					if r.labels[i] == NoRegion {
+						r.labels[i] = id
+						size++
+						queue = append(queue, next)
+					}
+				})
+			}
+
+			r.sizes = append(r.sizes, size)
+
+			if size > r.sizes[r.largest] {
+				r.largest = id
+			}
+		}
+	}
+
+	return r
+}
+
+// getRegions returns the World's regions, labelling them
+// again if a tile has changed since they were last used.
+func (w *World) getRegions() *regions {
+	if w.regions == nil {
+		w.regions = w.labelRegions()
+	}
+
+	return w.regions
+}
+
+// Region returns the region which the tile at (x, y)
+// is in. Two tiles are in the same region if, and only
+// if, a ship can sail from one to the other. Impassable
+// and out of bounds tiles are in NoRegion.
+func (w *World) Region(x, y int) int {
+	if !w.InBounds(x, y) {
+		return NoRegion
+	}
+
+	return w.getRegions().labels[y*w.Width+x]
+}
+
+// RegionSize returns the amount of tiles in a region.
+func (w *World) RegionSize(region int) int {
+	r := w.getRegions()
+
+	if region <= NoRegion || region >= len(r.sizes) {
+		return 0
+	}
+
+	return r.sizes[region]
+}
+
+// LargestRegion returns the region with the most tiles,
+// or NoRegion if nothing is passable.
+func (w *World) LargestRegion() int {
+	return w.getRegions().largest
+}
+
+// Connected checks whether a ship can sail between two
+// coordinates.
+func (w *World) Connected(a, b geom.Coord) bool {
+	region := w.Region(int(a.X), int(a.Y))
+
+	return region != NoRegion && region == w.Region(int(b.X), int(b.Y))
+}
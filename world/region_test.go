@@ -0,0 +1,194 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// regionRows has three bodies of water: 12 tiles on the
+// left, 15 on the right, and a lagoon of one tile inside
+// the island on the right.
+var regionRows = []string{
+	"...#......",
+	"...#.###..",
+	"...#.#.#..",
+	"...#.###..",
+}
+
+func TestRegions(t *testing.T) {
+	w := parseWorld(regionRows...)
+
+	var (
+		left   = w.Region(0, 0)
+		right  = w.Region(9, 0)
+		lagoon = w.Region(6, 2)
+	)
+
+	if left == NoRegion || right == NoRegion || lagoon == NoRegion {
+		t.Fatalf("expected water to be in a region, got %d, %d and %d", left, right, lagoon)
+	}
+
+	if left == right || left == lagoon || right == lagoon {
+		t.Fatalf("expected three different regions, got %d, %d and %d", left, right, lagoon)
+	}
+
+	for y, row := range regionRows {
+		for x, c := range row {
+			want := NoRegion
+
+			switch {
+			case c == '#':
+			case x < 3:
+				want = left
+			case x == 6 && y == 2:
+				want = lagoon
+			default:
+				want = right
+			}
+
+			if got := w.Region(x, y); got != want {
+				t.Errorf("expected (%d, %d) to be in region %d, got %d", x, y, want, got)
+			}
+		}
+	}
+
+	sizes := map[int]int{left: 12, right: 15, lagoon: 1, NoRegion: 0}
+
+	for region, want := range sizes {
+		if got := w.RegionSize(region); got != want {
+			t.Errorf("expected region %d to have %d tiles, got %d", region, want, got)
+		}
+	}
+
+	if w.LargestRegion() != right {
+		t.Errorf("expected the largest region to be %d, got %d", right, w.LargestRegion())
+	}
+
+	if w.Region(-1, 0) != NoRegion || w.Region(0, 4) != NoRegion {
+		t.Error("expected tiles outside the world to be in NoRegion")
+	}
+
+	connected := []struct {
+		a, b geom.Coord
+		want bool
+	}{
+		{geom.Coord{X: 0, Y: 0}, geom.Coord{X: 2, Y: 3}, true},
+		{geom.Coord{X: 4, Y: 3}, geom.Coord{X: 9, Y: 0}, true},
+		{geom.Coord{X: 0, Y: 0}, geom.Coord{X: 9, Y: 0}, false},
+		{geom.Coord{X: 9, Y: 0}, geom.Coord{X: 6, Y: 2}, false},
+		{geom.Coord{X: 3, Y: 0}, geom.Coord{X: 3, Y: 1}, false},
+		{geom.Coord{X: 0, Y: 0}, geom.Coord{X: 0, Y: 9}, false},
+	}
+
+	for _, test := range connected {
+		if got := w.Connected(test.a, test.b); got != test.want {
+			t.Errorf("expected Connected(%v, %v) to be %v", test.a, test.b, test.want)
+		}
+	}
+}
+
+func TestRegionsJoined(t *testing.T) {
+	w := parseWorld(regionRows...)
+
+	var (
+		left  = geom.Coord{X: 0, Y: 0}
+		right = geom.Coord{X: 9, Y: 0}
+	)
+
+	if _, ok := w.FindPath(left, right); ok {
+		t.Fatal("found a path through the wall")
+	}
+
+	w.SetTile(3, 1, Water)
+
+	if !w.Connected(left, right) {
+		t.Fatal("the regions weren't joined after the wall was opened")
+	}
+
+	if size := w.RegionSize(w.Region(0, 0)); size != 12+15+1 {
+		t.Errorf("expected the joined region to have 28 tiles, got %d", size)
+	}
+
+	if w.LargestRegion() != w.Region(0, 0) {
+		t.Error("expected the joined region to be the largest")
+	}
+
+	path, ok := w.FindPath(left, right)
+	if !ok {
+		t.Fatal("no path through the opening")
+	}
+
+	checkPath(t, w, path, left, right)
+}
+
+func TestFindPathDisconnected(t *testing.T) {
+	w := parseWorld(regionRows...)
+
+	// Without a hierarchy to search, FindPath can only
+	// return if it rejects the path straight away.
+	w.Hierarchy = nil
+
+	tests := [][2]geom.Coord{
+		{{X: 0, Y: 0}, {X: 9, Y: 0}},
+		{{X: 9, Y: 0}, {X: 6, Y: 2}},
+		{{X: 0, Y: 0}, {X: 3, Y: 0}},
+	}
+
+	for _, test := range tests {
+		if path, ok := w.FindPath(test[0], test[1]); ok || len(path) != 0 {
+			t.Errorf("expected no path from %v to %v, got %v", test[0], test[1], path)
+		}
+	}
+}
+
+func TestFindFreeSpaceRegion(t *testing.T) {
+	w := parseWorld(regionRows...)
+
+	for i := 0; i < 100; i++ {
+		if pos := w.FindFreeSpace(); w.Region(int(pos.X), int(pos.Y)) != w.LargestRegion() {
+			t.Fatalf("spawned at %v, outside the largest region", pos)
+		}
+	}
+
+	// A spawn zone limits spawns to itself, even if it's
+	// not in the largest region.
+	w.SpawnZone = &Zone{X: 0, Y: 0, Width: 3, Height: 4}
+
+	for i := 0; i < 100; i++ {
+		if pos := w.FindFreeSpace(); !w.SpawnZone.Contains(int(pos.X), int(pos.Y)) {
+			t.Fatalf("spawned at %v, outside the spawn zone", pos)
+		}
+	}
+
+	// Unless there's no water in it.
+	w.SpawnZone = &Zone{X: 3, Y: 0, Width: 1, Height: 4}
+
+	for i := 0; i < 100; i++ {
+		if pos := w.FindFreeSpace(); w.Region(int(pos.X), int(pos.Y)) != w.LargestRegion() {
+			t.Fatalf("spawned at %v, outside the largest region", pos)
+		}
+	}
+}
+
+func TestParseZone(t *testing.T) {
+	good := map[string]Zone{
+		"1,2,3,4":     {X: 1, Y: 2, Width: 3, Height: 4},
+		"0,0,1,1":     {X: 0, Y: 0, Width: 1, Height: 1},
+		"-5,10,20,30": {X: -5, Y: 10, Width: 20, Height: 30},
+	}
+
+	for s, want := range good {
+		if z, err := ParseZone(s); err != nil || z != want {
+			t.Errorf("%q: expected %v, got %v (%v)", s, want, z, err)
+		}
+	}
+
+	bad := []string{"", "1,2,3", "a,b,c,d", "1,2,0,4", "1,2,3,-4", "1 2 3 4"}
+
+	for _, s := range bad {
+		if z, err := ParseZone(s); err == nil {
+			t.Errorf("%q: expected an error, got %v", s, z)
+		}
+	}
+}
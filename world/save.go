@@ -0,0 +1,308 @@
+package world
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// SaveVersion is the version of the format written by
+// Save. It's increased whenever the format changes, and
+// a migration is added to bring older saves up to date.
+const SaveVersion = 1
+
+// saveMagic is written at the start of every save, so
+// other files can't be loaded by mistake.
+var saveMagic = [4]byte{'P', 'O', '7', 'W'}
+
+// maxHeaderSize is the largest header Load will read,
+// in bytes, so a corrupt save can't use up all the
+// memory.
+const maxHeaderSize = 16 << 20
+
+// A saved World is made up of:
+//
+//  - saveMagic
+//  - the version, as a big-endian uint16
+//  - the length of the header, as a big-endian uint32,
+//    followed by the header as JSON
+//  - the tiles, row by row, as runs of the same tile.
+//    Each run is its length then its index in the
+//    header's palette, both as uvarints
+//
+// Tiles are saved by name, through the palette, rather
+// than by their ids. This means new tiles can be added,
+// or the existing ones reordered, without breaking old
+// saves.
+
+// saveHeader holds everything in a save apart from the
+// tiles themselves.
+type saveHeader struct {
+	Seed          int64
+	Width, Height int
+	SpawnZone     *Zone
+	Spawns        []Zone
+	Ports         []Port
+	Areas         []Area
+	Metadata      map[string]string
+
+	// Palette holds the names of the tiles used in the
+	// save. Tiles are stored as indices into it.
+	Palette []string
+}
+
+// save is a World as it's stored on disk, before its
+// tiles are looked up in the palette.
+type save struct {
+	header saveHeader
+	tiles  []uint16
+}
+
+// migrations bring a save from an older version up to
+// date. migrations[i] upgrades a version i+1 save to
+// version i+2, so there should always be SaveVersion-1
+// of them. They're mostly needed when a tile is renamed
+// or removed, since added ones don't affect old saves.
+var migrations = []func(*save) error{}
+
+// Save writes the World to out, in a format which Load
+// can read back.
+func (w *World) Save(out io.Writer) error {
+	var (
+		header = saveHeader{
+			Seed:      w.Seed,
+			Width:     w.Width,
+			Height:    w.Height,
+			SpawnZone: w.SpawnZone,
+			Spawns:    w.Spawns,
+			Ports:     w.Ports,
+			Areas:     w.Areas,
+			Metadata:  w.Metadata,
+		}
+
+		palette = make(map[Tile]uint64)
+	)
+
+	for _, row := range w.Tiles {
+		for _, t := range row {
+			if _, ok := palette[t]; !ok {
+				palette[t] = uint64(len(header.Palette))
+				header.Palette = append(header.Palette, t.GetData().Name)
+			}
+		}
+	}
+
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	buf := bufio.NewWriter(out)
+
+	buf.Write(saveMagic[:])
+	binary.Write(buf, binary.BigEndian, uint16(SaveVersion))
+	binary.Write(buf, binary.BigEndian, uint32(len(encoded)))
+	buf.Write(encoded)
+
+	var (
+		scratch = make([]byte, binary.MaxVarintLen64)
+		run     uint64
+		current Tile
+	)
+
+	flush := func() {
+		if run == 0 {
+			return
+		}
+
+		buf.Write(scratch[:binary.PutUvarint(scratch, run)])
+		buf.Write(scratch[:binary.PutUvarint(scratch, palette[current])])
+	}
+
+	for _, row := range w.Tiles {
+		for _, t := range row {
+			if t != current {
+				flush()
+
+				current = t
+				run = 0
+			}
+
+			run++
+		}
+	}
+
+	flush()
+
+	return buf.Flush()
+}
+
+// Load reads a World written by Save. Saves from older
+// versions are migrated to the current one.
+func Load(in io.Reader) (*World, error) {
+	buf := bufio.NewReader(in)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(buf, magic[:]); err != nil || magic != saveMagic {
+		return nil, errors.New("load: not a saved world")
+	}
+
+	var (
+		version uint16
+		length  uint32
+	)
+
+	if err := binary.Read(buf, binary.BigEndian, &version); err != nil {
+		return nil, fmt.Errorf("load: reading version: %s", err)
+	}
+
+	if version == 0 || version > SaveVersion {
+		return nil, fmt.Errorf("load: unsupported version %d, expected at most %d", version, SaveVersion)
+	}
+
+	if err := binary.Read(buf, binary.BigEndian, &length); err != nil {
+		return nil, fmt.Errorf("load: reading header: %s", err)
+	}
+
+	if length > maxHeaderSize {
+		return nil, fmt.Errorf("load: header is too big, at %d bytes", length)
+	}
+
+	encoded := make([]byte, length)
+	if _, err := io.ReadFull(buf, encoded); err != nil {
+		return nil, fmt.Errorf("load: reading header: %s", err)
+	}
+
+	var s save
+
+	if err := json.Unmarshal(encoded, &s.header); err != nil {
+		return nil, fmt.Errorf("load: invalid header: %s", err)
+	}
+
+	if err := CheckSize(s.header.Width, s.header.Height); err != nil {
+		return nil, fmt.Errorf("load: %s", err)
+	}
+
+	if len(s.header.Palette) > math.MaxUint16 {
+		return nil, errors.New("load: too many tiles in the palette")
+	}
+
+	s.tiles = make([]uint16, 0, s.header.Width*s.header.Height)
+
+	for len(s.tiles) < cap(s.tiles) {
+		run, err := binary.ReadUvarint(buf)
+		if err != nil {
+			return nil, fmt.Errorf("load: reading tiles: %s", err)
+		}
+
+		index, err := binary.ReadUvarint(buf)
+		if err != nil {
+			return nil, fmt.Errorf("load: reading tiles: %s", err)
+		}
+
+		if run == 0 || run > uint64(cap(s.tiles)-len(s.tiles)) {
+			return nil, errors.New("load: tiles don't fit the world's size")
+		}
+
+		if index >= uint64(len(s.header.Palette)) {
+			return nil, fmt.Errorf("load: tile %d isn't in the palette", index)
+		}
+
+		for i := uint64(0); i < run; i++ {
+			s.tiles = append(s.tiles, uint16(index))
+		}
+	}
+
+	for v := int(version); v < SaveVersion; v++ {
+		if err := migrations[v-1](&s); err != nil {
+			return nil, fmt.Errorf("load: migrating from version %d: %s", v, err)
+		}
+	}
+
+	return s.world()
+}
+
+// world makes a World from an up to date save.
+func (s *save) world() (*World, error) {
+	palette := make([]Tile, len(s.header.Palette))
+
+	for i, name := range s.header.Palette {
+		t, ok := TileNamed(name)
+		if !ok {
+			return nil, fmt.Errorf("load: unknown tile %q", name)
+		}
+
+		palette[i] = t
+	}
+
+	w := blank(s.header.Width, s.header.Height)
+
+	w.Seed = s.header.Seed
+	w.SpawnZone = s.header.SpawnZone
+	w.Spawns = s.header.Spawns
+	w.Ports = s.header.Ports
+	w.Areas = s.header.Areas
+	w.Metadata = s.header.Metadata
+
+	for i, index := range s.tiles {
+		if int(index) >= len(palette) {
+			return nil, fmt.Errorf("load: tile %d isn't in the palette", index)
+		}
+
+		w.Tiles[i/w.Width][i%w.Width] = palette[index]
+	}
+
+	w.MakeGraph()
+
+	return w, nil
+}
+
+// SaveFile saves the World to the file at path. The old
+// file is only replaced once the new one is completely
+// written, so a crash part of the way through doesn't
+// lose it.
+func (w *World) SaveFile(path string) error {
+	return WriteFile(path, w.Save)
+}
+
+// WriteFile calls write with a temporary file next to
+// path, then moves the file into place, so path is never
+// left half written.
+func WriteFile(path string, write func(io.Writer) error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFile loads a World from the file at path.
+func LoadFile(path string) (*World, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return Load(f)
+}
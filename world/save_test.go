@@ -0,0 +1,232 @@
+package world
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// encodeSave writes a save by hand, with the given
+// version and header, and tiles as pairs of run lengths
+// and palette indices.
+func encodeSave(version uint16, header saveHeader, runs ...uint64) []byte {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		panic(err)
+	}
+
+	var (
+		buf     bytes.Buffer
+		scratch = make([]byte, binary.MaxVarintLen64)
+	)
+
+	buf.Write(saveMagic[:])
+	binary.Write(&buf, binary.BigEndian, version)
+	binary.Write(&buf, binary.BigEndian, uint32(len(encoded)))
+	buf.Write(encoded)
+
+	for _, n := range runs {
+		buf.Write(scratch[:binary.PutUvarint(scratch, n)])
+	}
+
+	return buf.Bytes()
+}
+
+// savedHeader reads the header back out of a save.
+func savedHeader(t *testing.T, data []byte) saveHeader {
+	var (
+		header saveHeader
+		length = binary.BigEndian.Uint32(data[6:10])
+	)
+
+	if err := json.Unmarshal(data[10:10+length], &header); err != nil {
+		t.Fatal(err)
+	}
+
+	return header
+}
+
+func TestSaveLoad(t *testing.T) {
+	rows := []string{
+		"##...",
+		"#sdd.",
+		"rr..#",
+	}
+
+	w := parseWorld(rows...)
+
+	w.Seed = 42
+	w.SpawnZone = &Zone{X: 2, Y: 0, Width: 3, Height: 2}
+	w.Spawns = []Zone{{X: 4, Y: 0, Width: 1, Height: 1}}
+	w.Ports = []Port{{Name: "Harbour", Pos: geom.Coord{X: 3, Y: 2}}}
+	w.Areas = []Area{{Name: "Bay", Zone: Zone{X: 1, Y: 1, Width: 3, Height: 1}}}
+	w.Metadata = map[string]string{
+		"positions": `{"anne":{"X":2,"Y":0},"mary":{"X":4,"Y":1}}`,
+		"name":      "test",
+	}
+
+	var buf bytes.Buffer
+
+	if err := w.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tiles are saved as indices into the palette, in the
+	// order they first appear, rather than by their ids.
+	want := []string{"land", "water", "shallow water", "deep water", "reef"}
+	if palette := savedHeader(t, buf.Bytes()).Palette; !reflect.DeepEqual(palette, want) {
+		t.Errorf("expected the palette %q, got %q", want, palette)
+	}
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTiles(t, loaded, rows...)
+
+	if loaded.Seed != w.Seed || !reflect.DeepEqual(loaded.SpawnZone, w.SpawnZone) {
+		t.Errorf("expected seed %d and spawn zone %v, got %d and %v", w.Seed, w.SpawnZone, loaded.Seed, loaded.SpawnZone)
+	}
+
+	if !reflect.DeepEqual(loaded.Spawns, w.Spawns) || !reflect.DeepEqual(loaded.Ports, w.Ports) || !reflect.DeepEqual(loaded.Areas, w.Areas) {
+		t.Errorf("expected %v, %v and %v, got %v, %v and %v", w.Spawns, w.Ports, w.Areas, loaded.Spawns, loaded.Ports, loaded.Areas)
+	}
+
+	if !reflect.DeepEqual(loaded.Metadata, w.Metadata) {
+		t.Errorf("expected the metadata %v, got %v", w.Metadata, loaded.Metadata)
+	}
+
+	if loaded.Graph == nil || loaded.Graph.At(4, 2) == nil {
+		t.Error("the loaded world has no path-finding graph")
+	}
+}
+
+func TestLoadPalette(t *testing.T) {
+	// The palette is in a different order to the tiles'
+	// ids, so the indices have to be looked up.
+	data := encodeSave(SaveVersion, saveHeader{
+		Width:   3,
+		Height:  2,
+		Palette: []string{"reef", "land", "water"},
+	}, 2, 1, 1, 0, 2, 2, 1, 1)
+
+	w, err := Load(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTiles(t, w,
+		"##r",
+		"..#",
+	)
+}
+
+func TestLoadErrors(t *testing.T) {
+	var (
+		header = saveHeader{Width: 2, Height: 2, Palette: []string{"water", "land"}}
+		good   = encodeSave(SaveVersion, header, 3, 0, 1, 1)
+	)
+
+	if _, err := Load(bytes.NewReader(good)); err != nil {
+		t.Fatalf("the valid save didn't load: %s", err)
+	}
+
+	tooBig := append([]byte{}, good...)
+	binary.BigEndian.PutUint32(tooBig[6:], maxHeaderSize+1)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "not a saved world"},
+		{"bad magic", append([]byte("PNG!"), good[4:]...), "not a saved world"},
+		{"version 0", encodeSave(0, header, 3, 0, 1, 1), "unsupported version 0"},
+		{"future version", encodeSave(SaveVersion+1, header, 3, 0, 1, 1), "unsupported version"},
+		{"truncated version", good[:5], "reading version"},
+		{"truncated header", good[:20], "reading header"},
+		{"huge header", tooBig, "header is too big"},
+		{"truncated tiles", good[:len(good)-1], "reading tiles"},
+		{"missing tiles", encodeSave(SaveVersion, header, 3, 0), "reading tiles"},
+		{"overlong run", encodeSave(SaveVersion, header, 3, 0, 2, 1), "don't fit"},
+		{"empty run", encodeSave(SaveVersion, header, 0, 0, 4, 1), "don't fit"},
+		{"index outside the palette", encodeSave(SaveVersion, header, 3, 0, 1, 2), "isn't in the palette"},
+		{"unknown tile", encodeSave(SaveVersion, saveHeader{Width: 1, Height: 1, Palette: []string{"lava"}}, 1, 0), `unknown tile "lava"`},
+		{"bad size", encodeSave(SaveVersion, saveHeader{Width: 0, Height: 1, Palette: []string{"water"}}), "invalid world size"},
+	}
+
+	for _, test := range tests {
+		_, err := Load(bytes.NewReader(test.data))
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestSaveFile(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+
+	var (
+		path = filepath.Join(dir, "test.world")
+		w    = parseWorld("#..", ".r.")
+	)
+
+	if err := w.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTiles(t, loaded, "#..", ".r.")
+
+	if _, err := LoadFile(filepath.Join(dir, "missing.world")); err == nil {
+		t.Error("expected an error loading a missing file")
+	}
+}
+
+func TestWriteFileFailure(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+
+	path := filepath.Join(dir, "test.world")
+
+	if err := ioutil.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("failed")
+
+	err := WriteFile(path, func(out io.Writer) error {
+		out.Write([]byte("half written"))
+		return failed
+	})
+
+	if err != failed {
+		t.Errorf("expected the callback's error, got %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != "old" {
+		t.Errorf("expected the old file to be left, got %q (%v)", data, err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Errorf("expected the temporary file to be removed, got %d files (%v)", len(files), err)
+	}
+}
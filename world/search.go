@@ -0,0 +1,154 @@
+package world
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// bounds is a rectangular area of a Graph, from (x0, y0)
+// up to, but not including, (x1, y1).
+type bounds struct {
+	x0, y0, x1, y1 int
+}
+
+func (b bounds) contains(pos geom.Coord) bool {
+	x, y := int(pos.X), int(pos.Y)
+	return x >= b.x0 && y >= b.y0 && x < b.x1 && y < b.y1
+}
+
+// A localSearch holds the results of searching for
+// paths without leaving an area. Tiles are indexed by
+// their position in the area, row by row.
+type localSearch struct {
+	b    bounds
+	dist []float64
+	prev []int
+}
+
+// search runs Dijkstra's algorithm from a tile, without
+// leaving the given area. If a target is given, it stops
+// as soon as the target is reached. If blocked is given,
+// the tiles it returns true for are avoided.
+func (g *Graph) search(b bounds, from geom.Coord, target *geom.Coord, blocked func(geom.Coord) bool) *localSearch {
+	var (
+		width = b.x1 - b.x0
+		size  = width * (b.y1 - b.y0)
+		s     = &localSearch{
+			b:    b,
+			dist: make([]float64, size),
+			prev: make([]int, size),
+		}
+		done  = make([]bool, size)
+		queue = &coordQueue{}
+	)
+
+	for i := range s.dist {
+		s.dist[i] = math.Inf(1)
+		s.prev[i] = -1
+	}
+
+	s.dist[s.index(from)] = 0
+	heap.Push(queue, coordItem{pos: from})
+
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(coordItem)
+		i := s.index(item.pos)
+
+		if done[i] {
+			continue
+		}
+
+		done[i] = true
+
+		if target != nil && item.pos == *target {
+			break
+		}
+
+		node := g.AtCoord(item.pos)
+
+		node.eachNeighbour(func(next *Node) {
+			if !b.contains(next.Pos) || blocked != nil && blocked(next.Pos) {
+				return
+			}
+
+			var (
+				j = s.index(next.Pos)
+				d = s.dist[i] + node.PathNeighborCost(next)
+			)
+
+			if d < s.dist[j] {
+				s.dist[j] = d
+				s.prev[j] = i
+
+				heap.Push(queue, coordItem{pos: next.Pos, priority: d})
+			}
+		})
+	}
+
+	return s
+}
+
+func (s *localSearch) index(pos geom.Coord) int {
+	return (int(pos.Y)-s.b.y0)*(s.b.x1-s.b.x0) + int(pos.X) - s.b.x0
+}
+
+func (s *localSearch) coord(i int) geom.Coord {
+	width := s.b.x1 - s.b.x0
+
+	return geom.Coord{
+		X: uint(s.b.x0 + i%width),
+		Y: uint(s.b.y0 + i/width),
+	}
+}
+
+// cost returns the cost of reaching a tile, and whether
+// it could be reached at all.
+func (s *localSearch) cost(pos geom.Coord) (float64, bool) {
+	if !s.b.contains(pos) {
+		return 0, false
+	}
+
+	d := s.dist[s.index(pos)]
+
+	return d, !math.IsInf(d, 1)
+}
+
+// pathTo returns the path to a tile which was reached,
+// not including the tile the search started from.
+func (s *localSearch) pathTo(pos geom.Coord) []geom.Coord {
+	var path []geom.Coord
+
+	for i := s.index(pos); s.prev[i] >= 0; i = s.prev[i] {
+		path = append(path, s.coord(i))
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path
+}
+
+type coordItem struct {
+	pos      geom.Coord
+	priority float64
+}
+
+// A coordQueue is a priority queue of coordinates, for
+// use with container/heap.
+type coordQueue []coordItem
+
+func (q coordQueue) Len() int            { return len(q) }
+func (q coordQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q coordQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *coordQueue) Push(x interface{}) { *q = append(*q, x.(coordItem)) }
+
+func (q *coordQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return item
+}
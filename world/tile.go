@@ -11,25 +11,100 @@ type TileData struct {
 	Name     string
 	Passable bool
 	Texture  string
-	Frames   int32
 
-	// If true, the renderer assumes the texture is 60x60,
-	// with 16 tiles at 16x16 each.
-	MarchSquares bool
+	// Frames is how many frames the tile's animation has,
+	// laid out side by side in its texture. Each frame
+	// lasts FrameTime seconds.
+	Frames    int32
+	FrameTime float64
+
+	// Phase staggers the animation, so each tile starts
+	// on a different frame to its neighbours.
+	Phase bool
+
+	// Cost is how expensive the tile is to sail through,
+	// compared to open water, which costs 1. It must be
+	// at least minCost.
+	Cost float64
+
+	// Colour is the colour of the tile in map images.
+	Colour Colour
+
+	// Autotile is how the tile's edges join up with its
+	// neighbours.
+	Autotile Autotile
+
+	// Transitions are textures to draw instead of Texture
+	// where the tile's edges overlap another tile. They're
+	// laid out in the same way as Texture, and have the
+	// same amount of frames.
+	Transitions map[Tile]string
 }
 
+// An Autotile is a way of picking which part of a tile's
+// texture to draw, depending on its neighbours.
+type Autotile int
+
+const (
+	// NoAutotile tiles are drawn in full, with no edges.
+	NoAutotile Autotile = iota
+
+	// Corners tiles use marching squares. Each frame of
+	// the texture is 4x4 tiles, where each is picked by
+	// which of its four corners touch the tile. Their
+	// edges spill half a tile over the tiles drawn before
+	// them.
+	Corners
+
+	// Blob tiles pick one of 47 textures, 8 to a row and
+	// 6 rows to a frame, by which of their 8 neighbours
+	// are the same tile. A diagonal neighbour only counts
+	// if both of the neighbours next to it do. They don't
+	// spill over other tiles.
+	Blob
+)
+
 const (
 	// Water is the tile which the sea is made from.
 	Water = iota
 
 	// Land makes up islands.
 	Land
+
+	// DeepWater is the open ocean, far from land.
+	DeepWater
+
+	// ShallowWater surrounds coasts.
+	ShallowWater
+
+	// Beach is the sand at the edge of the land.
+	Beach
+
+	// Grassland covers dry, low-lying land.
+	Grassland
+
+	// Forest covers wet, low-lying land.
+	Forest
+
+	// Rock makes up the highest land.
+	Rock
+
+	// Reef grows in wet shallows, and is slow and
+	// dangerous to sail over.
+	Reef
 )
 
 // Tiles is a list of all rendered tiles, in their
 // render order.
 var Tiles = []Tile{
 	Water,
+	DeepWater,
+	ShallowWater,
+	Reef,
+	Beach,
+	Grassland,
+	Forest,
+	Rock,
 	Land,
 }
 
@@ -39,24 +114,126 @@ type Colour [4]uint8
 
 var tileData = map[Tile]*TileData{
 	Water: {
-		Name:         "water",
-		Passable:     true,
-		Texture:      "water",
-		Frames:       1,
-		MarchSquares: false,
+		Name:      "water",
+		Passable:  true,
+		Texture:   "water",
+		Frames:    5,
+		FrameTime: 0.4,
+		Phase:     true,
+		Cost:      1,
+		Colour:    Colour{47, 111, 176, 255},
+		Autotile:  NoAutotile,
 	},
 
 	Land: {
-		Name:         "land",
-		Passable:     false,
-		Texture:      "sand",
-		Frames:       1,
-		MarchSquares: true,
+		Name:     "land",
+		Passable: false,
+		Texture:  "sand",
+		Frames:   1,
+		Cost:     1,
+		Colour:   Colour{201, 178, 122, 255},
+		Autotile: Corners,
+	},
+
+	DeepWater: {
+		Name:      "deep water",
+		Passable:  true,
+		Texture:   "deep-water",
+		Frames:    5,
+		FrameTime: 0.4,
+		Phase:     true,
+		Cost:      1,
+		Colour:    Colour{29, 74, 133, 255},
+		Autotile:  Corners,
+	},
+
+	ShallowWater: {
+		Name:      "shallow water",
+		Passable:  true,
+		Texture:   "shallow-water",
+		Frames:    5,
+		FrameTime: 0.4,
+		Phase:     true,
+		Cost:      1.5,
+		Colour:    Colour{77, 154, 208, 255},
+		Autotile:  Corners,
+	},
+
+	Beach: {
+		Name:     "beach",
+		Passable: false,
+		Texture:  "beach",
+		Frames:   1,
+		Cost:     1,
+		Colour:   Colour{232, 214, 160, 255},
+		Autotile: Corners,
+		Transitions: map[Tile]string{
+			Water:        "beach-surf",
+			DeepWater:    "beach-surf",
+			ShallowWater: "beach-surf",
+		},
+	},
+
+	Grassland: {
+		Name:     "grassland",
+		Passable: false,
+		Texture:  "grass",
+		Frames:   1,
+		Cost:     1,
+		Colour:   Colour{106, 168, 79, 255},
+		Autotile: Corners,
+	},
+
+	Forest: {
+		Name:     "forest",
+		Passable: false,
+		Texture:  "forest",
+		Frames:   1,
+		Cost:     1,
+		Colour:   Colour{47, 107, 52, 255},
+		Autotile: Corners,
+	},
+
+	Rock: {
+		Name:     "rock",
+		Passable: false,
+		Texture:  "rock",
+		Frames:   1,
+		Cost:     1,
+		Colour:   Colour{138, 133, 128, 255},
+		Autotile: Blob,
+	},
+
+	Reef: {
+		Name:     "reef",
+		Passable: true,
+		Texture:  "reef",
+		Frames:   1,
+		Cost:     4,
+		Colour:   Colour{217, 138, 138, 255},
+		Autotile: Corners,
 	},
 }
 
+// minCost is the cost of the cheapest tile to sail
+// through, which keeps path-finding estimates from ever
+// being more than the real cost.
+const minCost = 1.0
+
 // GetData returns the data struct associated with the
 // given Tile.
 func (t Tile) GetData() *TileData {
 	return tileData[t]
 }
+
+// TileNamed returns the Tile with the given name. The
+// boolean is false if there isn't one.
+func TileNamed(name string) (Tile, bool) {
+	for t, data := range tileData {
+		if data.Name == name {
+			return t, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,736 @@
+package world
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Zac-Garby/pieces-of-seven/geom"
+)
+
+// Maps made in the Tiled editor can be loaded, from
+// either its JSON or its TMX format, with LoadTiled.
+//
+// Each tile in a tileset which the map uses needs to
+// say which Tile it is, either with a string property
+// called "tile" or with its type, set to the Tile's
+// name, such as "shallow water". Tile layers are drawn
+// over each other in order, and empty tiles are Water.
+//
+// Objects are made into spawns, ports and areas by their
+// type, or by the name of their layer if they don't
+// have one, such as a layer called "ports":
+//
+//  - a "spawn" is added to the World's Spawns
+//  - a "port" is added to the World's Ports, at the
+//    middle of the object
+//  - a "region" is added to the World's Areas, and it
+//    needs a name
+//
+// Rectangles cover every tile they touch, and points
+// cover the tile they're in.
+
+// tiledFlipFlags are the top bits of a tile's id, which
+// say whether it's flipped or rotated.
+const tiledFlipFlags = 0xf0000000
+
+// These are the kinds of object a Tiled map can have.
+const (
+	tiledSpawn  = "spawn"
+	tiledPort   = "port"
+	tiledRegion = "region"
+)
+
+// tiledMap holds the parts of a Tiled map which are
+// needed to make a World, from either format.
+type tiledMap struct {
+	Width       int    `json:"width" xml:"width,attr"`
+	Height      int    `json:"height" xml:"height,attr"`
+	TileWidth   int    `json:"tilewidth" xml:"tilewidth,attr"`
+	TileHeight  int    `json:"tileheight" xml:"tileheight,attr"`
+	Orientation string `json:"orientation" xml:"orientation,attr"`
+	Infinite    bool   `json:"infinite" xml:"-"`
+
+	Tilesets []tiledTileset `json:"tilesets" xml:"-"`
+	Layers   []tiledLayer   `json:"layers" xml:"-"`
+}
+
+type tiledTileset struct {
+	FirstGID uint32      `json:"firstgid" xml:"firstgid,attr"`
+	Source   string      `json:"source" xml:"source,attr"`
+	Name     string      `json:"name" xml:"name,attr"`
+	Tiles    []tiledTile `json:"tiles" xml:"tile"`
+}
+
+type tiledTile struct {
+	ID         uint32          `json:"id" xml:"id,attr"`
+	Type       string          `json:"type" xml:"type,attr"`
+	Class      string          `json:"class" xml:"class,attr"`
+	Properties []tiledProperty `json:"properties" xml:"properties>property"`
+}
+
+type tiledProperty struct {
+	Name  string     `json:"name" xml:"name,attr"`
+	Value tiledValue `json:"value" xml:"value,attr"`
+
+	// Text holds the value of a multi-line property in a
+	// TMX map, which isn't an attribute.
+	Text string `json:"-" xml:",chardata"`
+}
+
+// A tiledValue is the value of a property, as a string.
+// In JSON maps they can also be numbers or booleans.
+type tiledValue string
+
+func (v *tiledValue) UnmarshalJSON(data []byte) error {
+	var value interface{}
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	*v = tiledValue(fmt.Sprint(value))
+
+	return nil
+}
+
+type tiledLayer struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Width       int             `json:"width"`
+	Height      int             `json:"height"`
+	Data        json.RawMessage `json:"data"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Objects     []tiledObject   `json:"objects"`
+	Layers      []tiledLayer    `json:"layers"`
+
+	// gids holds the layer's tiles, once they're decoded.
+	gids []uint32
+}
+
+type tiledObject struct {
+	ID         int             `json:"id" xml:"id,attr"`
+	Name       string          `json:"name" xml:"name,attr"`
+	Type       string          `json:"type" xml:"type,attr"`
+	Class      string          `json:"class" xml:"class,attr"`
+	X          float64         `json:"x" xml:"x,attr"`
+	Y          float64         `json:"y" xml:"y,attr"`
+	Width      float64         `json:"width" xml:"width,attr"`
+	Height     float64         `json:"height" xml:"height,attr"`
+	GID        uint32          `json:"gid" xml:"gid,attr"`
+	Properties []tiledProperty `json:"properties" xml:"properties>property"`
+}
+
+// LoadTiled loads a World from a Tiled map, in the JSON
+// format if the file ends in .json or .tmj, or TMX if
+// it ends in .tmx. Tilesets stored in separate files
+// are loaded from beside the map.
+func LoadTiled(path string) (*World, error) {
+	w, err := loadTiled(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return w, nil
+}
+
+func loadTiled(path string) (*World, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *tiledMap
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".tmj":
+		m, err = parseTiledJSON(data)
+
+	case ".tmx":
+		m, err = parseTMX(data)
+
+	default:
+		return nil, errors.New("expected a .json, .tmj or .tmx map")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, ts := range m.Tilesets {
+		if ts.Source == "" {
+			continue
+		}
+
+		loaded, err := loadTileset(filepath.Join(filepath.Dir(path), ts.Source))
+		if err != nil {
+			return nil, fmt.Errorf("tileset %s: %s", ts.Source, err)
+		}
+
+		loaded.FirstGID = ts.FirstGID
+		m.Tilesets[i] = *loaded
+	}
+
+	return m.world()
+}
+
+// loadTileset loads a tileset stored in its own file,
+// as either JSON or TSX.
+func loadTileset(path string) (*tiledTileset, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &tiledTileset{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".tsj":
+		err = json.Unmarshal(data, ts)
+
+	case ".tsx":
+		err = xml.Unmarshal(data, ts)
+
+	default:
+		return nil, errors.New("expected a .json, .tsj or .tsx tileset")
+	}
+
+	return ts, err
+}
+
+// parseTiledJSON reads a map in Tiled's JSON format.
+func parseTiledJSON(data []byte) (*tiledMap, error) {
+	m := &tiledMap{}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+
+	var decode func(layers []tiledLayer) error
+
+	decode = func(layers []tiledLayer) error {
+		for i := range layers {
+			l := &layers[i]
+
+			switch l.Type {
+			case "tilelayer":
+				gids, err := decodeJSONData(l)
+				if err != nil {
+					return fmt.Errorf("layer %q: %s", l.Name, err)
+				}
+
+				l.gids = gids
+
+			case "group":
+				if err := decode(l.Layers); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if m.Infinite {
+		return m, nil
+	}
+
+	return m, decode(m.Layers)
+}
+
+// decodeJSONData decodes a JSON tile layer's tiles,
+// which are either an array or a base64 string.
+func decodeJSONData(l *tiledLayer) ([]uint32, error) {
+	if l.Encoding == "base64" {
+		var text string
+
+		if err := json.Unmarshal(l.Data, &text); err != nil {
+			return nil, err
+		}
+
+		return decodeBase64Data(text, l.Compression)
+	}
+
+	var gids []uint32
+
+	err := json.Unmarshal(l.Data, &gids)
+
+	return gids, err
+}
+
+// decodeBase64Data decodes tiles stored as base64, in
+// either format, which are optionally compressed.
+func decodeBase64Data(text, compression string) ([]uint32, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+
+	switch compression {
+	case "":
+
+	case "zlib":
+		if r, err = zlib.NewReader(r); err != nil {
+			return nil, err
+		}
+
+	case "gzip":
+		if r, err = gzip.NewReader(r); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported compression %q, expected zlib, gzip or none", compression)
+	}
+
+	if data, err = ioutil.ReadAll(r); err != nil {
+		return nil, err
+	}
+
+	if len(data)%4 != 0 {
+		return nil, errors.New("tile data isn't a whole number of tiles")
+	}
+
+	gids := make([]uint32, len(data)/4)
+	for i := range gids {
+		gids[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+
+	return gids, nil
+}
+
+// These are the parts of a TMX map which can't be read
+// straight into the types above.
+type (
+	tmxLayer struct {
+		Name   string  `xml:"name,attr"`
+		Width  int     `xml:"width,attr"`
+		Height int     `xml:"height,attr"`
+		Data   tmxData `xml:"data"`
+	}
+
+	tmxData struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		Text        string `xml:",chardata"`
+
+		Tiles []struct {
+			GID uint32 `xml:"gid,attr"`
+		} `xml:"tile"`
+	}
+
+	tmxObjectGroup struct {
+		Name    string        `xml:"name,attr"`
+		Objects []tiledObject `xml:"object"`
+	}
+)
+
+// parseTMX reads a map in Tiled's TMX format. Its layers
+// are read one by one, since their order matters.
+func parseTMX(data []byte) (*tiledMap, error) {
+	var (
+		m = &tiledMap{}
+		d = xml.NewDecoder(bytes.NewReader(data))
+	)
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, errors.New("no <map> element")
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if start.Name.Local != "map" {
+			return nil, fmt.Errorf("expected <map>, got <%s>", start.Name.Local)
+		}
+
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "width":
+				m.Width, err = strconv.Atoi(attr.Value)
+			case "height":
+				m.Height, err = strconv.Atoi(attr.Value)
+			case "tilewidth":
+				m.TileWidth, err = strconv.Atoi(attr.Value)
+			case "tileheight":
+				m.TileHeight, err = strconv.Atoi(attr.Value)
+			case "orientation":
+				m.Orientation = attr.Value
+			case "infinite":
+				m.Infinite = attr.Value == "1"
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", attr.Name.Local, attr.Value)
+			}
+		}
+
+		m.Layers, err = parseTMXLayers(d, m)
+
+		return m, err
+	}
+}
+
+// parseTMXLayers reads the layers inside a <map> or a
+// <group>, until the element ends.
+func parseTMXLayers(d *xml.Decoder, m *tiledMap) ([]tiledLayer, error) {
+	var layers []tiledLayer
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := tok.(xml.EndElement); ok {
+			return layers, nil
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "tileset":
+			var ts tiledTileset
+
+			if err := d.DecodeElement(&ts, &start); err != nil {
+				return nil, err
+			}
+
+			m.Tilesets = append(m.Tilesets, ts)
+
+		case "layer":
+			var l tmxLayer
+
+			if err := d.DecodeElement(&l, &start); err != nil {
+				return nil, err
+			}
+
+			layer := tiledLayer{Type: "tilelayer", Name: l.Name, Width: l.Width, Height: l.Height}
+
+			if !m.Infinite {
+				if layer.gids, err = l.Data.gids(); err != nil {
+					return nil, fmt.Errorf("layer %q: %s", l.Name, err)
+				}
+			}
+
+			layers = append(layers, layer)
+
+		case "objectgroup":
+			var g tmxObjectGroup
+
+			if err := d.DecodeElement(&g, &start); err != nil {
+				return nil, err
+			}
+
+			layers = append(layers, tiledLayer{Type: "objectgroup", Name: g.Name, Objects: g.Objects})
+
+		case "group":
+			var name string
+
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "name" {
+					name = attr.Value
+				}
+			}
+
+			children, err := parseTMXLayers(d, m)
+			if err != nil {
+				return nil, err
+			}
+
+			layers = append(layers, tiledLayer{Type: "group", Name: name, Layers: children})
+
+		default:
+			if err := d.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// gids decodes a TMX tile layer's tiles, which can be
+// comma separated, base64, or separate elements.
+func (data tmxData) gids() ([]uint32, error) {
+	switch data.Encoding {
+	case "csv":
+		var gids []uint32
+
+		for _, field := range strings.Split(data.Text, ",") {
+			gid, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tile id %q", strings.TrimSpace(field))
+			}
+
+			gids = append(gids, uint32(gid))
+		}
+
+		return gids, nil
+
+	case "base64":
+		return decodeBase64Data(data.Text, data.Compression)
+
+	case "":
+		gids := make([]uint32, len(data.Tiles))
+		for i, tile := range data.Tiles {
+			gids[i] = tile.GID
+		}
+
+		return gids, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q, expected csv, base64 or none", data.Encoding)
+	}
+}
+
+// world makes a World from the map.
+func (m *tiledMap) world() (*World, error) {
+	if m.Orientation != "" && m.Orientation != "orthogonal" {
+		return nil, fmt.Errorf("unsupported %s orientation, expected orthogonal", m.Orientation)
+	}
+
+	if m.Infinite {
+		return nil, errors.New("infinite maps aren't supported")
+	}
+
+	if m.TileWidth <= 0 || m.TileHeight <= 0 {
+		return nil, fmt.Errorf("invalid tile size %dx%d", m.TileWidth, m.TileHeight)
+	}
+
+	if err := CheckSize(m.Width, m.Height); err != nil {
+		return nil, err
+	}
+
+	var (
+		w     = blank(m.Width, m.Height)
+		tiles = make(map[uint32]Tile)
+	)
+
+	var apply func(layers []tiledLayer) error
+
+	apply = func(layers []tiledLayer) error {
+		for _, l := range layers {
+			var err error
+
+			switch l.Type {
+			case "tilelayer":
+				err = m.applyTiles(w, l, tiles)
+
+			case "objectgroup":
+				err = m.applyObjects(w, l)
+
+			case "group":
+				err = apply(l.Layers)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := apply(m.Layers); err != nil {
+		return nil, err
+	}
+
+	w.MakeGraph()
+
+	return w, nil
+}
+
+// applyTiles draws a tile layer onto the World.
+func (m *tiledMap) applyTiles(w *World, l tiledLayer, tiles map[uint32]Tile) error {
+	if len(l.gids) != m.Width*m.Height {
+		return fmt.Errorf("layer %q: has %d tiles, expected %d", l.Name, len(l.gids), m.Width*m.Height)
+	}
+
+	for i, gid := range l.gids {
+		gid &^= tiledFlipFlags
+
+		if gid == 0 {
+			continue
+		}
+
+		x, y := i%m.Width, i/m.Width
+
+		t, ok := tiles[gid]
+		if !ok {
+			var err error
+
+			if t, err = m.tile(gid); err != nil {
+				return fmt.Errorf("layer %q: tile at (%d, %d): %s", l.Name, x, y, err)
+			}
+
+			tiles[gid] = t
+		}
+
+		w.Tiles[y][x] = t
+	}
+
+	return nil
+}
+
+// tile finds the Tile which a tile id stands for.
+func (m *tiledMap) tile(gid uint32) (Tile, error) {
+	var ts *tiledTileset
+
+	for i := range m.Tilesets {
+		if m.Tilesets[i].FirstGID <= gid && (ts == nil || m.Tilesets[i].FirstGID > ts.FirstGID) {
+			ts = &m.Tilesets[i]
+		}
+	}
+
+	if ts == nil {
+		return 0, fmt.Errorf("unknown tile id %d, which isn't in any tileset", gid)
+	}
+
+	id := gid - ts.FirstGID
+
+	for _, tile := range ts.Tiles {
+		if tile.ID != id {
+			continue
+		}
+
+		name := tile.Type
+		if name == "" {
+			name = tile.Class
+		}
+
+		for _, prop := range tile.Properties {
+			if prop.Name == "tile" {
+				name = prop.value()
+			}
+		}
+
+		if name == "" {
+			break
+		}
+
+		t, ok := TileNamed(name)
+		if !ok {
+			return 0, fmt.Errorf("tile %d of tileset %q is %q, which isn't a known tile", id, ts.Name, name)
+		}
+
+		return t, nil
+	}
+
+	return 0, fmt.Errorf("unknown tile id %d: tile %d of tileset %q has no \"tile\" property or type", gid, id, ts.Name)
+}
+
+func (p tiledProperty) value() string {
+	if p.Value == "" {
+		return strings.TrimSpace(p.Text)
+	}
+
+	return string(p.Value)
+}
+
+// applyObjects adds the spawns, ports and areas in an
+// object layer to the World.
+func (m *tiledMap) applyObjects(w *World, l tiledLayer) error {
+	for _, obj := range l.Objects {
+		kind := obj.Type
+		if kind == "" {
+			kind = obj.Class
+		}
+
+		if kind == "" {
+			kind = strings.TrimSuffix(l.Name, "s")
+		}
+
+		zone := m.zone(obj)
+
+		switch strings.ToLower(kind) {
+		case tiledSpawn:
+			w.Spawns = append(w.Spawns, zone)
+
+		case tiledPort:
+			w.Ports = append(w.Ports, Port{
+				Name: obj.Name,
+				Pos: geom.Coord{
+					X: uint(zone.X + zone.Width/2),
+					Y: uint(zone.Y + zone.Height/2),
+				},
+			})
+
+		case tiledRegion:
+			if obj.Name == "" {
+				return fmt.Errorf("layer %q: region %d has no name", l.Name, obj.ID)
+			}
+
+			w.Areas = append(w.Areas, Area{Name: obj.Name, Zone: zone})
+
+		default:
+			return fmt.Errorf("layer %q: object %d is a %q, expected a spawn, port or region", l.Name, obj.ID, kind)
+		}
+	}
+
+	return nil
+}
+
+// zone returns the tiles which an object covers,
+// clamped to the map.
+func (m *tiledMap) zone(obj tiledObject) Zone {
+	x, y := obj.X, obj.Y
+
+	// Tile objects are positioned by their bottom left
+	// corner, rather than their top left.
+	if obj.GID != 0 {
+		y -= obj.Height
+	}
+
+	var (
+		x0 = int(math.Floor(x / float64(m.TileWidth)))
+		y0 = int(math.Floor(y / float64(m.TileHeight)))
+		x1 = int(math.Ceil((x + obj.Width) / float64(m.TileWidth)))
+		y1 = int(math.Ceil((y + obj.Height) / float64(m.TileHeight)))
+	)
+
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+
+	x0, x1 = clamp(x0, 0, m.Width-1), clamp(x1, 1, m.Width)
+	y0, y1 = clamp(y0, 0, m.Height-1), clamp(y1, 1, m.Height)
+
+	return Zone{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+
+	if n > max {
+		return max
+	}
+
+	return n
+}
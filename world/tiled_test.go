@@ -0,0 +1,277 @@
+package world
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// terrainTSX is an external tileset, using each of the
+// ways a tile can say which Tile it is.
+const terrainTSX = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="terrain" tilewidth="16" tileheight="16" tilecount="3">
+ <tile id="0" type="land"/>
+ <tile id="1">
+  <properties>
+   <property name="tile" value="reef"/>
+  </properties>
+ </tile>
+ <tile id="2" class="deep water"/>
+</tileset>`
+
+// tiledGIDs are the tiles of the test maps, using the
+// terrain tileset from gid 1. The last one is flipped.
+var tiledGIDs = []uint32{
+	0, 1, 0, 0,
+	0, 0, 2, 0,
+	3, 0, 0, 1 | 0x80000000,
+}
+
+// tiledRows are the tiles the test maps should load as,
+// written as for parseWorld.
+var tiledRows = []string{
+	".#..",
+	"..r.",
+	"d..#",
+}
+
+// tmxMap makes a 4 by 3 TMX map, using terrainTSX, with
+// the given <data> element and object groups.
+func tmxMap(data, objects string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" renderorder="right-down" width="4" height="3" tilewidth="16" tileheight="16" infinite="0">
+ <tileset firstgid="1" source="terrain.tsx"/>
+ <group name="terrain">
+  <layer id="1" name="tiles" width="4" height="3">
+   %s
+  </layer>
+ </group>
+ %s
+</map>`, data, objects)
+}
+
+// tiledJSON is a JSON map with the same tiles as the
+// TMX maps, split between two layers in different
+// formats, and a tileset of its own.
+var tiledJSON = `{
+ "width": 4, "height": 3, "tilewidth": 16, "tileheight": 16,
+ "orientation": "orthogonal", "infinite": false,
+ "tilesets": [{
+  "firstgid": 1, "name": "terrain",
+  "tiles": [
+   {"id": 0, "type": "land"},
+   {"id": 1, "properties": [{"name": "tile", "type": "string", "value": "reef"}]},
+   {"id": 2, "class": "deep water"}
+  ]
+ }],
+ "layers": [
+  {"type": "tilelayer", "name": "base", "width": 4, "height": 3,
+   "data": [0, 1, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0]},
+  {"type": "group", "name": "top", "layers": [
+   {"type": "tilelayer", "name": "reefs", "width": 4, "height": 3,
+    "encoding": "base64", "compression": "gzip",
+    "data": "` + encodeGIDs([]uint32{0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 1}, "gzip") + `"}
+  ]},
+  {"type": "objectgroup", "name": "spawns", "objects": [
+   {"id": 1, "x": 9, "y": 20, "width": 0, "height": 0, "point": true}
+  ]}
+ ]
+}`
+
+// encodeGIDs encodes tiles as Tiled's base64 format,
+// optionally compressed with zlib or gzip.
+func encodeGIDs(gids []uint32, compression string) string {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+
+	switch compression {
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+
+	default:
+		binary.Write(&buf, binary.LittleEndian, gids)
+		return base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	binary.Write(w, binary.LittleEndian, gids)
+	w.Close()
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// loadTiledString writes a map, and terrainTSX beside
+// it, to a temporary directory and loads it.
+func loadTiledString(t *testing.T, name, data string) (*World, error) {
+	dir, remove := tempDir(t)
+	defer remove()
+
+	files := map[string]string{
+		"terrain.tsx": terrainTSX,
+		name:          data,
+	}
+
+	for file, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return LoadTiled(filepath.Join(dir, name))
+}
+
+func TestLoadTiledTMX(t *testing.T) {
+	var (
+		csv  []string
+		xmls []string
+	)
+
+	for _, gid := range tiledGIDs {
+		csv = append(csv, fmt.Sprint(gid))
+		xmls = append(xmls, fmt.Sprintf(`<tile gid="%d"/>`, gid))
+	}
+
+	encodings := map[string]string{
+		"csv":    `<data encoding="csv">` + strings.Join(csv, ",\n") + `</data>`,
+		"base64": `<data encoding="base64">` + encodeGIDs(tiledGIDs, "") + `</data>`,
+		"zlib":   `<data encoding="base64" compression="zlib">` + encodeGIDs(tiledGIDs, "zlib") + `</data>`,
+		"gzip":   `<data encoding="base64" compression="gzip">` + encodeGIDs(tiledGIDs, "gzip") + `</data>`,
+		"xml":    `<data>` + strings.Join(xmls, "") + `</data>`,
+	}
+
+	for name, data := range encodings {
+		w, err := loadTiledString(t, "map.tmx", tmxMap(data, ""))
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+
+		checkTiles(t, w, tiledRows...)
+	}
+}
+
+func TestLoadTiledJSON(t *testing.T) {
+	w, err := loadTiledString(t, "map.json", tiledJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTiles(t, w, tiledRows...)
+
+	if len(w.Spawns) != 1 || w.Spawns[0] != (Zone{X: 0, Y: 1, Width: 1, Height: 1}) {
+		t.Errorf("expected a spawn at (0, 1), got %v", w.Spawns)
+	}
+}
+
+func TestLoadTiledObjects(t *testing.T) {
+	objects := `
+ <objectgroup id="2" name="spawns">
+  <object id="1" x="16" y="16" width="32" height="16"/>
+ </objectgroup>
+ <objectgroup id="3" name="places">
+  <object id="2" name="Harbour" type="port" x="40" y="24"><point/></object>
+  <object id="3" name="Bay" class="region" x="0" y="0" width="20" height="20"/>
+ </objectgroup>`
+
+	w, err := loadTiledString(t, "map.tmx", tmxMap(`<data encoding="base64">`+encodeGIDs(tiledGIDs, "")+`</data>`, objects))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(w.Spawns) != 1 || w.Spawns[0] != (Zone{X: 1, Y: 1, Width: 2, Height: 1}) {
+		t.Errorf("expected a 2x1 spawn at (1, 1), got %v", w.Spawns)
+	}
+
+	if len(w.Ports) != 1 || w.Ports[0].Name != "Harbour" || w.Ports[0].Pos.X != 2 || w.Ports[0].Pos.Y != 1 {
+		t.Errorf("expected Harbour at (2, 1), got %v", w.Ports)
+	}
+
+	if len(w.Areas) != 1 || w.Areas[0].Name != "Bay" || w.Areas[0].Zone != (Zone{X: 0, Y: 0, Width: 2, Height: 2}) {
+		t.Errorf("expected Bay covering (0, 0) to (2, 2), got %v", w.Areas)
+	}
+
+	for i := 0; i < 10; i++ {
+		if pos := w.FindFreeSpace(); !w.Spawns[0].Contains(int(pos.X), int(pos.Y)) {
+			t.Fatalf("expected to spawn in %v, got %v", w.Spawns[0], pos)
+		}
+	}
+}
+
+func TestLoadTiledErrors(t *testing.T) {
+	tmx := tmxMap(`<data encoding="base64">`+encodeGIDs(tiledGIDs, "")+`</data>`, "")
+
+	tests := []struct {
+		name, file, data string
+		from, to, err    string
+	}{
+		{
+			"unknown tile property", "map.json", tiledJSON,
+			`"value": "reef"`, `"value": "lava"`,
+			`"lava", which isn't a known tile`,
+		},
+		{
+			"unknown tile id", "map.json", tiledJSON,
+			`[0, 1, 0, 0,`, `[0, 9, 0, 0,`,
+			"unknown tile id 9",
+		},
+		{
+			"unknown object kind", "map.json", tiledJSON,
+			`"id": 1, "x": 9`, `"id": 1, "type": "chest", "x": 9`,
+			`object 1 is a "chest"`,
+		},
+		{
+			"region without a name", "map.json", tiledJSON,
+			`"id": 1, "x": 9`, `"id": 1, "type": "region", "x": 9`,
+			"region 1 has no name",
+		},
+		{
+			"infinite json", "map.json", tiledJSON,
+			`"infinite": false`, `"infinite": true`,
+			"infinite maps aren't supported",
+		},
+		{
+			"infinite tmx", "map.tmx", tmx,
+			`infinite="0"`, `infinite="1"`,
+			"infinite maps aren't supported",
+		},
+		{
+			"bad orientation", "map.tmx", tmx,
+			`orientation="orthogonal"`, `orientation="isometric"`,
+			"unsupported isometric orientation",
+		},
+		{
+			"bad encoding", "map.tmx", tmx,
+			`encoding="base64"`, `encoding="hex"`,
+			`unsupported encoding "hex"`,
+		},
+		{
+			"missing tileset", "map.tmx", tmx,
+			`source="terrain.tsx"`, `source="missing.tsx"`,
+			"tileset missing.tsx",
+		},
+	}
+
+	for _, test := range tests {
+		if !strings.Contains(test.data, test.from) {
+			t.Fatalf("%s: the map doesn't contain %s", test.name, test.from)
+		}
+
+		_, err := loadTiledString(t, test.file, strings.Replace(test.data, test.from, test.to, 1))
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+}
@@ -1,218 +1,229 @@
 package world
 
 import (
+	"fmt"
 	"math/rand"
 
-	"time"
-
 	"github.com/Zac-Garby/pieces-of-seven/geom"
-	"github.com/Zac-Garby/pieces-of-seven/loader"
-	"github.com/veandco/go-sdl2/sdl"
 )
 
-// Width is the width, in Tiles, of the World.
-const Width = 256
+// DefaultWidth is the default width, in Tiles, of a World.
+const DefaultWidth = 256
+
+// DefaultHeight is the default height, in Tiles, of a World.
+const DefaultHeight = 256
 
-// Height is the height, in Tiles, of the World.
-const Height = 256
+// MaxSize is the largest width or height, in Tiles, which
+// a World can have.
+const MaxSize = 4096
 
 // The World is a 2d slice of Tiles.
-// Coordinate (x, y) is at index [y-1][x-1].
+// Coordinate (x, y) is at index [y][x].
 // It also stores a path-finding graph.
 type World struct {
-	Tiles [Height][Width]Tile
+	Width, Height int
+	Tiles         [][]Tile
 	*Graph
 
-	frame int32
-}
+	// Hierarchy finds long paths through the Graph much
+	// more quickly than searching it directly.
+	Hierarchy *Hierarchy
 
-// New creates a new World instance.
-func New() *World {
-	world := &World{}
-	world.MakeGraph()
+	// Seed is the seed the World was generated from.
+	Seed int64
 
-	return world
-}
+	// SpawnZone, if it's set, is where ships start.
+	SpawnZone *Zone
 
-// Render renders the world to the given
-// SDL renderer.
-func (w *World) Render(rend *sdl.Renderer, ld *loader.Loader, viewOffset *geom.Vector, width, height int) {
-	for _, tile := range Tiles {
-		data := tile.GetData()
-		tex := ld.Textures[data.Texture]
-		frame := w.frame % data.Frames
+	// Spawns are where ships start if there's no
+	// SpawnZone. Each ship starts in a random one.
+	Spawns []Zone
 
-		srcs, dsts := w.getRectsOfType(tile, viewOffset, width, height, frame)
+	// Ports are the World's harbours.
+	Ports []Port
 
-		for i, rect := range dsts {
-			rend.Copy(tex, &srcs[i], &rect)
-		}
-	}
-}
+	// Areas are named parts of the World.
+	Areas []Area
 
-func (w *World) getTexRectForMarchingSquares(x, y int) sdl.Rect {
-	bits := 0
+	// Metadata holds anything else which should be saved
+	// along with the World.
+	Metadata map[string]string
 
-	// The if statements below cover the neighbours
-	// in this order:
-	//
-	// 1 2 3
-	// 4   5
-	// 6 7 8
+	regions    *regions
+	flowFields map[geom.Coord]*FlowField
+	flowOrder  []geom.Coord
+	rng        *rand.Rand
+	loaded     [][]bool
+	versions   []uint64
+}
 
-	matches := func(x, y int) bool {
-		if x < 0 || y < 0 || x >= Width || y >= Height {
-			return true
-		}
+// New creates a new World instance, filled with
+// Water, which is width by height Tiles in size.
+func New(width, height int) *World {
+	world := blank(width, height)
+	world.MakeGraph()
 
-		return w.Tiles[y][x] == Land
-	}
+	return world
+}
 
-	if w.Tiles[y][x] == Land {
-		bits = 0xF
+// blank creates a World filled with Water, without a
+// path-finding graph. It's for when the Tiles are about
+// to be filled in, so the graph is only made once they
+// are.
+func blank(width, height int) *World {
+	world := &World{
+		Width:  width,
+		Height: height,
+		Tiles:  make([][]Tile, height),
 	}
 
-	if matches(x-1, y-1) {
-		bits |= 1 << 3
+	for y := range world.Tiles {
+		world.Tiles[y] = make([]Tile, width)
 	}
 
-	if matches(x, y-1) {
-		bits |= 1<<3 | 1<<2
-	}
+	return world
+}
 
-	if matches(x+1, y-1) {
-		bits |= 1 << 2
+// CheckSize returns an error if a World can't be
+// width by height Tiles in size.
+func CheckSize(width, height int) error {
+	if width <= 0 || height <= 0 || width > MaxSize || height > MaxSize {
+		return fmt.Errorf("invalid world size %dx%d: each side must be between 1 and %d", width, height, MaxSize)
 	}
 
-	if matches(x-1, y) {
-		bits |= 1<<3 | 1<<0
-	}
+	return nil
+}
 
-	if matches(x+1, y) {
-		bits |= 1<<2 | 1<<1
-	}
+// InBounds checks whether (x, y) is inside the World.
+func (w *World) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < w.Width && y < w.Height
+}
 
-	if matches(x-1, y+1) {
-		bits |= 1 << 0
+// MakeGraph creates a path-finding graph from the World.
+func (w *World) MakeGraph() {
+	w.Graph = &Graph{
+		nodes: make([][]*Node, w.Height),
 	}
 
-	if matches(x, y+1) {
-		bits |= 1<<0 | 1<<1
-	}
+	for y := w.Height - 1; y >= 0; y-- {
+		w.Graph.nodes[y] = make([]*Node, w.Width)
+
+		for x := 0; x < w.Width; x++ {
+			node := &Node{
+				Graph: w.Graph,
+				Pos:   geom.Coord{X: uint(x), Y: uint(y)},
+				Tile:  w.Tiles[y][x],
+			}
 
-	if matches(x+1, y+1) {
-		bits |= 1 << 1
+			w.Graph.nodes[y][x] = node
+		}
 	}
 
-	var texx, texy int32
+	w.Hierarchy = NewHierarchy(w.Graph)
+	w.regions = nil
+	w.invalidateFlowFields()
+}
+
+// SetTile changes the tile at (x, y), keeping the
+// path-finding graph up to date.
+func (w *World) SetTile(x, y int, t Tile) {
+	if !w.InBounds(x, y) {
+		return
+	}
 
-	for i := 0; i < bits; i++ {
-		texx++
+	w.Tiles[y][x] = t
 
-		if texx > 3 {
-			texx = 0
-			texy++
-		}
+	if node := w.Graph.At(x, y); node != nil {
+		node.Tile = t
 	}
 
-	rect := sdl.Rect{
-		X: texx * 15,
-		Y: texy * 15,
-		W: 15,
-		H: 15,
+	if w.Hierarchy != nil {
+		w.Hierarchy.Invalidate(x, y)
 	}
 
-	return rect
+	w.regions = nil
+	w.invalidateFlowFields()
+	w.touchChunk(x, y)
 }
 
-// Tick steps the world by one tick.
-func (w *World) Tick() {
-	w.frame += 1
+// FindPath finds a path from a coordinate to another,
+// using the World's Hierarchy. If they're in different
+// regions, it gives up straight away.
+func (w *World) FindPath(from, to geom.Coord) ([]geom.Coord, bool) {
+	if !w.Connected(from, to) {
+		return []geom.Coord{}, false
+	}
+
+	return w.Hierarchy.FindPath(from, to)
 }
 
-func (w *World) getRectsOfType(t Tile, viewOffset *geom.Vector, width, height int, frame int32) ([]sdl.Rect, []sdl.Rect) {
-	dests := []sdl.Rect{}
-	srcs := []sdl.Rect{}
-
-	// Calculate the amount of visible tiles, with some
-	// padding on the side just in case
-	tilesWide := width/TileSize + 3
-	tilesHigh := height/TileSize + 3
-	startX := int(viewOffset.X)/TileSize - 1
-	startY := int(viewOffset.Y)/TileSize - 1
-
-	if t.GetData().MarchSquares {
-		for y := startY; y < startY+tilesHigh; y++ {
-			for x := startX; x < startX+tilesWide; x++ {
-				if y < Height && x < Width && y >= 0 && x >= 0 {
-					dests = append(dests, sdl.Rect{
-						X: int32(x*TileSize) - int32(viewOffset.X),
-						Y: int32(y*TileSize) - int32(viewOffset.Y),
-						W: TileSize,
-						H: TileSize,
-					})
-
-					srcs = append(srcs, w.getTexRectForMarchingSquares(x, y))
-				}
-			}
-		}
-	} else {
-		texRect := sdl.Rect{
-			X: 15 * frame,
-			Y: 0,
-			W: 15,
-			H: 15,
+// FindFreeSpace finds a random coordinate in the world
+// for a ship to start at. If there's a SpawnZone, or
+// else some Spawns, it's in the part of the zone, or a
+// random spawn, which can reach the most water.
+// Otherwise, it's in the largest region, so a
+// ship never starts stranded in a tiny lagoon. The
+// coordinates come from the World's own random number
+// generator, seeded with its Seed, so they're
+// reproducible too.
+func (w *World) FindFreeSpace() geom.Coord {
+	if w.rng == nil {
+		w.rng = rand.New(rand.NewSource(w.Seed))
+	}
+
+	zone := Zone{Width: w.Width, Height: w.Height}
+	region := w.LargestRegion()
+
+	if w.SpawnZone != nil {
+		if r := w.largestRegionIn(*w.SpawnZone); r != NoRegion {
+			zone, region = *w.SpawnZone, r
 		}
+	} else if len(w.Spawns) > 0 {
+		spawn := w.Spawns[w.rng.Intn(len(w.Spawns))]
 
-		for y := startY; y < startY+tilesHigh; y++ {
-			for x := startX; x < startX+tilesWide; x++ {
-				if y < Height && x < Width && y >= 0 && x >= 0 && (w.Tiles[y][x] == t || t == Water) {
-					dests = append(dests, sdl.Rect{
-						X: int32(x*TileSize) - int32(viewOffset.X),
-						Y: int32(y*TileSize) - int32(viewOffset.Y),
-						W: TileSize,
-						H: TileSize,
-					})
-
-					srcs = append(srcs, texRect)
-				}
-			}
+		if r := w.largestRegionIn(spawn); r != NoRegion {
+			zone, region = spawn, r
 		}
 	}
 
-	return srcs, dests
-}
-
-// MakeGraph creates a path-finding graph from the World.
-func (w *World) MakeGraph() {
-	w.Graph = &Graph{}
+	if region == NoRegion {
+		return geom.Coord{}
+	}
 
-	for y := Height - 1; y >= 0; y-- {
-		for x := 0; x < Width; x++ {
-			node := &Node{
-				Graph: w.Graph,
-				Pos:   geom.Coord{X: uint(x), Y: uint(y)},
-				Tile:  w.Tiles[y][x],
-			}
+	for {
+		x := zone.X + w.rng.Intn(zone.Width)
+		y := zone.Y + w.rng.Intn(zone.Height)
 
-			w.Graph[y][x] = node
+		if w.Region(x, y) == region {
+			return geom.Coord{X: uint(x), Y: uint(y)}
 		}
 	}
 }
 
-// FindFreeSpace finds a random coordinate in the world
-// in which there is no unpassable tile.
-func (w *World) FindFreeSpace() geom.Coord {
-	rand.Seed(int64(time.Now().Nanosecond()))
+// largestRegionIn returns the region which has the most
+// tiles in the given zone, or NoRegion if none of the
+// zone is passable.
+func (w *World) largestRegionIn(zone Zone) int {
+	var (
+		counts = make(map[int]int)
+		best   = NoRegion
+	)
+
+	for y := zone.Y; y < zone.Y+zone.Height; y++ {
+		for x := zone.X; x < zone.X+zone.Width; x++ {
+			region := w.Region(x, y)
+			if region == NoRegion {
+				continue
+			}
 
-	for {
-		coord := geom.Coord{
-			X: uint(rand.Int() % (Width - 1)),
-			Y: uint(rand.Int() % (Height - 1)),
-		}
+			counts[region]++
 
-		if w.Tiles[coord.Y][coord.X].GetData().Passable {
-			return coord
+			if best == NoRegion || counts[region] > counts[best] ||
+				counts[region] == counts[best] && w.RegionSize(region) > w.RegionSize(best) {
+				best = region
+			}
 		}
 	}
+
+	return best
 }
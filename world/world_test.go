@@ -0,0 +1,66 @@
+package world
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// parseWorld makes a World from rows of characters, one
+// for each tile:
+//
+//	. water
+//	d deep water
+//	s shallow water
+//	r reef
+//	# land
+func parseWorld(rows ...string) *World {
+	w := New(len(rows[0]), len(rows))
+
+	tiles := map[rune]Tile{
+		'.': Water,
+		'd': DeepWater,
+		's': ShallowWater,
+		'r': Reef,
+		'#': Land,
+	}
+
+	for y, row := range rows {
+		for x, c := range row {
+			w.Tiles[y][x] = tiles[c]
+		}
+	}
+
+	w.MakeGraph()
+
+	return w
+}
+
+// checkTiles fails the test if the World's tiles aren't
+// the ones in rows, written as for parseWorld.
+func checkTiles(t *testing.T, w *World, rows ...string) {
+	want := parseWorld(rows...)
+
+	if w.Width != want.Width || w.Height != want.Height {
+		t.Fatalf("expected a %dx%d world, got %dx%d", want.Width, want.Height, w.Width, w.Height)
+	}
+
+	for y := range want.Tiles {
+		for x, tile := range want.Tiles[y] {
+			if got := w.Tiles[y][x]; got != tile {
+				t.Errorf("tile at (%d, %d) is %s, expected %s", x, y, got.GetData().Name, tile.GetData().Name)
+			}
+		}
+	}
+}
+
+// tempDir makes a temporary directory, and returns a
+// function to remove it.
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, func() { os.RemoveAll(dir) }
+}